@@ -6,6 +6,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -24,66 +25,210 @@ var (
 	Root = filepath.Join(filepath.Dir(b), "..")
 )
 
+// Options for flexible config loading
+type options struct {
+	RequireSecretFilePerms bool              // Fail when a secret config file is readable by group/others
+	RequireEnvVars         bool              // Fail when a ${VAR} token references an unset environment variable
+	SecretDir              string            // Directory of mounted secret files merged after config files
+	EnvPrefix              string            // Prefix for env var overrides (e.g. MYSVC -> MYSVC_DATABASE_HOST)
+	KnownEnvs              []string          // Allowed RUNTIME_ENV values (any when empty)
+	EnvAliases             map[string]string // Extra env var names for config keys (env var -> dotted key)
+	NoEnvOverrides         bool              // Don't let env vars override config values (LoadEnv)
+}
+
+// Option configures config loading behavior
+type Option func(*options)
+
+// RequireSecretFilePerms fails loading when an additional config file that looks like a
+// secrets file (e.g. secrets.yaml) has permissions looser than 0600
+var RequireSecretFilePerms Option = func(o *options) {
+	o.RequireSecretFilePerms = true
+}
+
+// RequireEnvVars fails loading when a config value references an unset environment variable
+// By default unknown ${VAR} tokens expand to an empty string
+var RequireEnvVars Option = func(o *options) {
+	o.RequireEnvVars = true
+}
+
+// WithEnvPrefix namespaces env var overrides so services sharing a host don't collide
+// e.g. WithEnvPrefix("MYSVC") reads MYSVC_DATABASE_HOST for database.host; unprefixed vars are ignored
+func WithEnvPrefix(prefix string) Option {
+	return func(o *options) {
+		o.EnvPrefix = strings.TrimSuffix(prefix, "_")
+	}
+}
+
+// WithKnownEnvs fails fast with a clear error when the environment is not one of envs,
+// instead of an unclear "config file not found" for a typo like RUNTIME_ENV=prdo
+func WithKnownEnvs(envs ...string) Option {
+	return func(o *options) {
+		o.KnownEnvs = envs
+	}
+}
+
+// WithEnvAlias maps legacy env var names to config keys, e.g. {"PGHOST": "database.host"}
+// Aliases are read in addition to the derived names (DATABASE_HOST), which win when both are set,
+// and are not affected by WithEnvPrefix
+func WithEnvAlias(aliases map[string]string) Option {
+	return func(o *options) {
+		if o.EnvAliases == nil {
+			o.EnvAliases = map[string]string{}
+		}
+		for envVar, key := range aliases {
+			o.EnvAliases[envVar] = key
+		}
+	}
+}
+
 // InitViper initializes Viper configuration with environment-based config loading
 // It looks for config files named config.{RUNTIME_ENV}.yaml (e.g., config.local.yaml, config.prod.yaml)
 // and supports additional config files through the additional_configs pattern
+// String values may reference environment variables as ${VAR} or $VAR (use $$ for a literal $)
 func InitViper(configPaths ...string) {
+	InitViperWithOptions(configPaths)
+}
+
+// InitViperWithOptions initializes Viper configuration like InitViper with additional options
+func InitViperWithOptions(configPaths []string, opts ...Option) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Determine environment (defaults to "local" if RUNTIME_ENV not set)
 	env := os.Getenv("RUNTIME_ENV")
 	if env == "" {
 		env = "local"
 	}
 
+	files := keyFiles{}
+	if err := loadViper(viper.GetViper(), env, configPaths, o, files); err != nil {
+		zap.L().Fatal("can't init config", zap.Error(err))
+	}
+	setKeyFiles(files, o.EnvPrefix, o.EnvAliases)
+}
+
+// Reset clears the global viper instance, the key provenance recorded by InitViper, bound flags and Watch callbacks
+// Call it from test setup/cleanup so keys from one test don't leak into the next
+func Reset() {
+	viper.Reset()
+	setKeyFiles(keyFiles{}, "", nil)
+	resetBoundFlags()
+	resetWatchers()
+}
+
+// loadViper loads config.{env}.yaml, additional configs and env var overrides into v
+// The environment is passed explicitly so callers can load any environment into their own viper instance
+// If files is non-nil, it records which file each key was loaded from
+func loadViper(v *viper.Viper, env string, configPaths []string, o options, files keyFiles) error {
+	if len(o.KnownEnvs) > 0 && !slices.Contains(o.KnownEnvs, env) {
+		return errors.Errorf("unknown environment '%s'; expected one of %s", env, strings.Join(o.KnownEnvs, ", "))
+	}
+
 	// Look for config.{env}.yaml files
-	viper.SetConfigName(fmt.Sprintf("config.%s", env))
+	v.SetConfigName(fmt.Sprintf("config.%s", env))
 
 	// Add custom config paths if provided
 	for _, cp := range configPaths {
+		if filepath.IsAbs(cp) {
+			v.AddConfigPath(cp)
+			continue
+		}
 		// Join with Root so we can run app from any directory
-		viper.AddConfigPath(path.Join(Root, cp))
+		v.AddConfigPath(path.Join(Root, cp))
 	}
 
 	// Add standard config search paths
-	viper.AddConfigPath(".")                        // Current directory
-	viper.AddConfigPath("./config")                 // ./config/ directory
-	viper.AddConfigPath("./configs")                // ./configs/ directory
-	viper.AddConfigPath(path.Join(Root, "configs")) // Project root configs/ directory
+	v.AddConfigPath(".")                        // Current directory
+	v.AddConfigPath("./config")                 // ./config/ directory
+	v.AddConfigPath("./configs")                // ./configs/ directory
+	v.AddConfigPath(path.Join(Root, "configs")) // Project root configs/ directory
 
 	// Load the main config file
-	if err := viper.MergeInConfig(); err != nil {
-		zap.L().Fatal("can't load config", zap.Error(err))
+	if err := v.MergeInConfig(); err != nil {
+		return errors.Wrap(err, "can't load config")
+	}
+	if err := files.record(v.ConfigFileUsed()); err != nil {
+		return err
 	}
 
 	// Load additional config files specified in additional_configs array
-	if err := loadAdditionalConfigs(Root); err != nil {
-		zap.L().Fatal("can't load additional config", zap.Error(err))
+	if err := loadAdditionalConfigs(v, Root, o, files); err != nil {
+		return errors.Wrap(err, "can't load additional config")
+	}
+
+	// Expand ${VAR} tokens in file values, before env vars and flags are bound so their values stay literal
+	if err := expandEnv(v, o); err != nil {
+		return errors.Wrap(err, "can't expand env vars in config")
 	}
 
 	// Enable automatic environment variable binding
 	// This allows DATABASE_HOST env var to override database.host config
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	// With a prefix, viper prepends PREFIX_ to the replaced key (MYSVC_DATABASE_HOST)
+	if !o.NoEnvOverrides {
+		v.SetEnvPrefix(o.EnvPrefix)
+		v.AutomaticEnv()
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+		for envVar, key := range o.EnvAliases {
+			if err := v.BindEnv(key, envVar); err != nil {
+				return errors.Wrapf(err, "can't bind env alias %s to %s", envVar, key)
+			}
+		}
+	}
 
-	// Merge environment variables with config
-	if err := viper.MergeInConfig(); err != nil {
-		zap.L().Fatal("can't merge config with env var", zap.Error(err))
+	// Merge mounted secrets last so they override config files (env vars still take precedence)
+	// Secret values are taken literally, without ${VAR} expansion
+	if o.SecretDir != "" {
+		if err := loadSecretDir(v, o.SecretDir, files); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // loadAdditionalConfigs loads additional configuration files specified in the main config
 // This pattern allows you to split configuration into multiple files for better organization
 // Example: additional_configs: ["./shared.yaml", "./secrets.yaml"]
-func loadAdditionalConfigs(configDir string) error {
-	configFiles := viper.GetStringSlice("additional_configs")
+func loadAdditionalConfigs(v *viper.Viper, configDir string, o options, files keyFiles) error {
+	configFiles := v.GetStringSlice("additional_configs")
 	for _, file := range configFiles {
 		abs, err := filepath.Abs(path.Join(configDir, file))
 		if err != nil {
 			return errors.Wrapf(err, "can't get absolute path for %s", file)
 		}
-		viper.SetConfigFile(abs)
-		if err := viper.MergeInConfig(); err != nil {
+		if isSecretFile(abs) {
+			if err := checkSecretFilePerms(abs); err != nil {
+				if o.RequireSecretFilePerms {
+					return err
+				}
+				zap.L().Warn("insecure secret config file", zap.Error(err))
+			}
+		}
+		v.SetConfigFile(abs)
+		if err := v.MergeInConfig(); err != nil {
 			return errors.Wrapf(err, "can't load config file: %s", abs)
 		}
+		if err := files.record(abs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSecretFile reports whether a config file name looks like it holds secrets (e.g. secrets.yaml, db-secret.yaml)
+func isSecretFile(file string) bool {
+	return strings.Contains(strings.ToLower(filepath.Base(file)), "secret")
+}
+
+// checkSecretFilePerms returns an error if the file is accessible by group or others (looser than 0600)
+func checkSecretFilePerms(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return errors.Wrapf(err, "can't stat secret config file: %s", file)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		return errors.Errorf("secret config file %s has permissions %04o, expected 0600 or stricter", file, perm)
 	}
 	return nil
 }
@@ -95,3 +240,15 @@ func Unmarshal(c any) error {
 	}
 	return nil
 }
+
+// UnmarshalKey unmarshals a single config subtree (e.g. "trading") into the provided struct
+// Lets a package load just its slice of config without depending on AppConfig
+func UnmarshalKey(key string, c any) error {
+	if !viper.IsSet(key) {
+		return errors.Errorf("config key %s is not set", key)
+	}
+	if err := viper.UnmarshalKey(key, c); err != nil {
+		return errors.Wrapf(err, "failed when unmarshal config key %s", key)
+	}
+	return nil
+}
@@ -1,7 +1,18 @@
 package config
 
 import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/zap/zapcore"
 )
 
 func TestInitViper(t *testing.T) {
@@ -144,3 +155,669 @@ func TestInitViperWithUnmarshal(t *testing.T) {
 		t.Error("Config is empty: trading config not set")
 	}
 }
+
+func TestUnmarshalKey(t *testing.T) {
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Cleanup(Reset)
+
+	InitViper()
+
+	var trading TradingConfig
+	if err := UnmarshalKey("trading", &trading); err != nil {
+		t.Fatalf("Failed to unmarshal trading config: %v", err)
+	}
+	if trading.MaxOrdersPerUser != 1000 {
+		t.Errorf("Expected max_orders_per_user 1000, got %d", trading.MaxOrdersPerUser)
+	}
+
+	if err := UnmarshalKey("tradng", &trading); err == nil {
+		t.Error("Expected error for a key that is not set")
+	}
+}
+
+func TestSecretFilePerms(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "secrets.yaml")
+	if err := os.WriteFile(secretFile, []byte("database:\n  password: s3cret\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+	// WriteFile is subject to umask, so set the mode explicitly
+	if err := os.Chmod(secretFile, 0o644); err != nil {
+		t.Fatalf("Failed to chmod secret file: %v", err)
+	}
+
+	Reset()
+	t.Cleanup(Reset)
+	viper.Set("additional_configs", []string{"secrets.yaml"})
+
+	// Strict mode rejects a world-readable secret file
+	if err := loadAdditionalConfigs(viper.GetViper(), dir, options{RequireSecretFilePerms: true}, nil); err == nil {
+		t.Fatal("Expected error for secret file with 0644 permissions")
+	}
+
+	// Non-strict mode only warns
+	if err := loadAdditionalConfigs(viper.GetViper(), dir, options{}, nil); err != nil {
+		t.Fatalf("Expected non-strict mode to load secret file, got: %v", err)
+	}
+
+	// Strict mode accepts 0600
+	if err := os.Chmod(secretFile, 0o600); err != nil {
+		t.Fatalf("Failed to chmod secret file: %v", err)
+	}
+	if err := loadAdditionalConfigs(viper.GetViper(), dir, options{RequireSecretFilePerms: true}, nil); err != nil {
+		t.Fatalf("Expected 0600 secret file to pass, got: %v", err)
+	}
+	if got := viper.GetString("database.password"); got != "s3cret" {
+		t.Errorf("Expected database.password 's3cret', got %s", got)
+	}
+}
+
+func TestLoadEnvAndDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig := func(env, content string) {
+		if err := os.WriteFile(filepath.Join(dir, "config."+env+".yaml"), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s config: %v", env, err)
+		}
+	}
+	writeConfig("staging", `service_name: config_demo
+database:
+  host: staging-db
+  port: 5432
+redis:
+  addresses:
+    - staging-redis:6379
+trading:
+  max_orders_per_user: 100
+`)
+	writeConfig("prod", `service_name: config_demo
+database:
+  host: prod-db
+  port: 5432
+redis:
+  addresses:
+    - prod-redis:6379
+trading:
+  max_orders_per_user: 100
+`)
+
+	// Neither RUNTIME_ENV nor env var overrides may influence LoadEnv
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Setenv("DATABASE_HOST", "env-db")
+
+	staging, err := LoadEnv("staging", dir)
+	if err != nil {
+		t.Fatalf("Failed to load staging config: %v", err)
+	}
+	prod, err := LoadEnv("prod", dir)
+	if err != nil {
+		t.Fatalf("Failed to load prod config: %v", err)
+	}
+
+	if staging.Database.Host != "staging-db" {
+		t.Errorf("Expected staging database host 'staging-db', got %s", staging.Database.Host)
+	}
+	if prod.Database.Host != "prod-db" {
+		t.Errorf("Expected prod database host 'prod-db', got %s", prod.Database.Host)
+	}
+
+	diffs := Diff(staging, prod)
+	fields := map[string]FieldDiff{}
+	for _, d := range diffs {
+		fields[d.Field] = d
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("Expected 2 differing fields, got %d: %+v", len(diffs), diffs)
+	}
+	if d, ok := fields["database.host"]; !ok || d.A != "staging-db" || d.B != "prod-db" {
+		t.Errorf("Expected database.host diff staging-db -> prod-db, got %+v", d)
+	}
+	if _, ok := fields["redis.addresses"]; !ok {
+		t.Error("Expected redis.addresses diff")
+	}
+
+	if _, err := LoadEnv("missing", dir); err == nil {
+		t.Error("Expected error loading unknown environment")
+	}
+}
+
+func TestExplainKey(t *testing.T) {
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Setenv("DATABASE_HOST", "env-db")
+
+	InitViper()
+
+	src := ExplainKey("database.host")
+	if src.Kind != SourceEnv || src.Name != "DATABASE_HOST" {
+		t.Errorf("Expected database.host from env DATABASE_HOST, got %+v", src)
+	}
+
+	src = ExplainKey("trading.max_orders_per_user")
+	if src.Kind != SourceFile || filepath.Base(src.Name) != "config.local.yaml" {
+		t.Errorf("Expected trading.max_orders_per_user from config.local.yaml, got %+v", src)
+	}
+
+	src = ExplainKey("monitoring.metrics_port")
+	if src.Kind != SourceFile || filepath.Base(src.Name) != "additional.yaml" {
+		t.Errorf("Expected monitoring.metrics_port from additional.yaml, got %+v", src)
+	}
+
+	viper.SetDefault("feature.enabled", true)
+	t.Cleanup(Reset)
+	if src := ExplainKey("feature.enabled"); src.Kind != SourceDefault {
+		t.Errorf("Expected feature.enabled from default, got %+v", src)
+	}
+
+	if src := ExplainKey("does.not.exist"); src.Kind != SourceUnset {
+		t.Errorf("Expected unknown key to be unset, got %+v", src)
+	}
+}
+
+func TestReset(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.alpha.yaml"), []byte("service_name: alpha\nalpha_only: true\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write alpha config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.beta.yaml"), []byte("service_name: beta\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write beta config: %v", err)
+	}
+	t.Cleanup(Reset)
+
+	t.Setenv("RUNTIME_ENV", "alpha")
+	InitViper(dir)
+	if !viper.GetBool("alpha_only") {
+		t.Fatal("Expected alpha_only to be set after loading alpha config")
+	}
+
+	Reset()
+	if viper.IsSet("service_name") {
+		t.Error("Expected service_name to be cleared by Reset")
+	}
+	if src := ExplainKey("alpha_only"); src.Kind != SourceUnset {
+		t.Errorf("Expected alpha_only provenance to be cleared by Reset, got %+v", src)
+	}
+
+	t.Setenv("RUNTIME_ENV", "beta")
+	InitViper(dir)
+	if viper.IsSet("alpha_only") {
+		t.Error("Expected alpha_only to be gone after re-init with beta config")
+	}
+	if got := viper.GetString("service_name"); got != "beta" {
+		t.Errorf("Expected service_name 'beta', got %s", got)
+	}
+}
+
+func TestEnvInterpolation(t *testing.T) {
+	dir := t.TempDir()
+	content := `service_name: ${SERVICE}-$REGION
+dsn: "postgres://app:${DB_PASSWORD}@db/app"
+price: "$$5"
+database:
+  host: ${UNSET_DB_HOST}
+  port: 5432
+redis:
+  addresses:
+    - ${REDIS_HOST}:6379
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.interp.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv("RUNTIME_ENV", "interp")
+	t.Setenv("SERVICE", "orders")
+	t.Setenv("REGION", "eu")
+	t.Setenv("DB_PASSWORD", "p@ss")
+	t.Setenv("REDIS_HOST", "cache")
+	t.Cleanup(Reset)
+
+	InitViper(dir)
+
+	var cfg struct {
+		ServiceName string         `mapstructure:"service_name"`
+		DSN         string         `mapstructure:"dsn"`
+		Price       string         `mapstructure:"price"`
+		Database    DatabaseConfig `mapstructure:"database"`
+		Redis       RedisConfig    `mapstructure:"redis"`
+	}
+	if err := Unmarshal(&cfg); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+
+	if cfg.ServiceName != "orders-eu" {
+		t.Errorf("Expected service_name 'orders-eu', got %s", cfg.ServiceName)
+	}
+	if cfg.DSN != "postgres://app:p@ss@db/app" {
+		t.Errorf("Expected expanded dsn, got %s", cfg.DSN)
+	}
+	if cfg.Price != "$5" {
+		t.Errorf("Expected $$ to escape a literal $, got %s", cfg.Price)
+	}
+	if cfg.Database.Host != "" {
+		t.Errorf("Expected unset var to expand to empty, got %s", cfg.Database.Host)
+	}
+	if len(cfg.Redis.Addresses) != 1 || cfg.Redis.Addresses[0] != "cache:6379" {
+		t.Errorf("Expected redis addresses [cache:6379], got %v", cfg.Redis.Addresses)
+	}
+
+	// Strict mode rejects unset variables
+	err := loadViper(viper.New(), "interp", []string{dir}, options{RequireEnvVars: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "UNSET_DB_HOST") {
+		t.Errorf("Expected error naming UNSET_DB_HOST, got %v", err)
+	}
+}
+
+func TestEnvInterpolationKeepsOverrides(t *testing.T) {
+	dir := t.TempDir()
+	content := `token: ${TOKEN_SOURCE}
+password: ${DB_PASSWORD}
+`
+	if err := os.WriteFile(filepath.Join(dir, "config.interp.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv("RUNTIME_ENV", "interp")
+	t.Setenv("TOKEN_SOURCE", "from-file")
+	t.Setenv("DB_PASSWORD", "from-file")
+	// Env overrides are taken literally, "$" included
+	t.Setenv("TOKEN", "a$b$$c")
+	t.Cleanup(Reset)
+
+	InitViper(dir)
+
+	fs := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	fs.String("password", "", "database password")
+	if err := fs.Parse([]string{"--password=from-flag"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	BindFlags(fs)
+
+	if got := viper.GetString("token"); got != "a$b$$c" {
+		t.Errorf("Expected env value to stay literal, got %s", got)
+	}
+	if got := viper.GetString("password"); got != "from-flag" {
+		t.Errorf("Expected flag to override expanded file value, got %s", got)
+	}
+}
+
+func TestInitViperWithSecretDir(t *testing.T) {
+	dir := t.TempDir()
+	// Mimic the Kubernetes layout: keys are symlinks into a hidden ..data directory
+	dataDir := filepath.Join(dir, "..data")
+	if err := os.Mkdir(dataDir, 0o700); err != nil {
+		t.Fatalf("Failed to create data dir: %v", err)
+	}
+	secrets := map[string]string{
+		"database_password": "s3cret$x\n",
+		"database_host":     "secret-db",
+		"database_port":     "6000",
+	}
+	for name, content := range secrets {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write secret %s: %v", name, err)
+		}
+		if err := os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Failed to link secret %s: %v", name, err)
+		}
+	}
+
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Setenv("DATABASE_PORT", "7000")
+	t.Cleanup(Reset)
+
+	InitViperWithSecretDir(dir)
+
+	if got := viper.GetString("database.password"); got != "s3cret$x" {
+		t.Errorf("Expected database.password 's3cret$x' (trimmed, unexpanded), got %q", got)
+	}
+	if got := viper.GetString("database.host"); got != "secret-db" {
+		t.Errorf("Expected secret to override config file database.host, got %s", got)
+	}
+	if got := viper.GetInt("database.port"); got != 7000 {
+		t.Errorf("Expected env var to override secret database.port, got %d", got)
+	}
+	if got := viper.GetString("service_name"); got != "config_demo" {
+		t.Errorf("Expected service_name from config file, got %s", got)
+	}
+	if src := ExplainKey("database.password"); src.Kind != SourceFile || src.Name != filepath.Join(dir, "database_password") {
+		t.Errorf("Expected database.password from secret file, got %+v", src)
+	}
+}
+
+func TestWithEnvPrefix(t *testing.T) {
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Setenv("MYSVC_DATABASE_HOST", "prefixed-db")
+	t.Setenv("DATABASE_PORT", "9999") // Unprefixed vars are ignored with a prefix
+	t.Cleanup(Reset)
+	Reset()
+
+	InitViperWithOptions(nil, WithEnvPrefix("MYSVC"))
+
+	if got := viper.GetString("database.host"); got != "prefixed-db" {
+		t.Errorf("Expected MYSVC_DATABASE_HOST to override database.host, got %s", got)
+	}
+	if got := viper.GetInt("database.port"); got != 5432 {
+		t.Errorf("Expected unprefixed DATABASE_PORT to be ignored, got %d", got)
+	}
+	if src := ExplainKey("database.host"); src.Kind != SourceEnv || src.Name != "MYSVC_DATABASE_HOST" {
+		t.Errorf("Expected database.host from env MYSVC_DATABASE_HOST, got %+v", src)
+	}
+}
+
+func TestWithKnownEnvs(t *testing.T) {
+	var o options
+	WithKnownEnvs("local", "staging", "prod")(&o)
+
+	if err := loadViper(viper.New(), "local", nil, o, nil); err != nil {
+		t.Errorf("Expected known env 'local' to load, got: %v", err)
+	}
+
+	err := loadViper(viper.New(), "prdo", nil, o, nil)
+	if err == nil {
+		t.Fatal("Expected error for unknown env 'prdo'")
+	}
+	want := "unknown environment 'prdo'; expected one of local, staging, prod"
+	if err.Error() != want {
+		t.Errorf("Expected error %q, got %q", want, err.Error())
+	}
+
+	// Without the option any env is searched for
+	if err := loadViper(viper.New(), "prdo", nil, options{}, nil); err == nil || strings.Contains(err.Error(), "unknown environment") {
+		t.Errorf("Expected config-not-found error without allowlist, got: %v", err)
+	}
+}
+
+func TestDatabaseDSN(t *testing.T) {
+	cfg := DatabaseConfig{
+		Host:     "db.internal",
+		Port:     5433,
+		User:     "app",
+		Password: "p@ss/w:rd",
+		Name:     "orders",
+	}
+
+	u, err := url.Parse(cfg.DSN())
+	if err != nil {
+		t.Fatalf("DSN is not parseable: %v", err)
+	}
+	if u.Scheme != "postgres" || u.Hostname() != "db.internal" || u.Port() != "5433" {
+		t.Errorf("Unexpected scheme/host in %s", cfg.DSN())
+	}
+	if password, _ := u.User.Password(); u.User.Username() != "app" || password != "p@ss/w:rd" {
+		t.Errorf("Expected credentials to round-trip, got %s", u.User)
+	}
+	if u.Path != "/orders" {
+		t.Errorf("Expected database 'orders', got %s", u.Path)
+	}
+	if got := u.Query().Get("sslmode"); got != "disable" {
+		t.Errorf("Expected sslmode to default to disable, got %s", got)
+	}
+
+	cfg.SSLMode = "verify-full"
+	u, err = url.Parse(cfg.DSN())
+	if err != nil {
+		t.Fatalf("DSN is not parseable: %v", err)
+	}
+	if got := u.Query().Get("sslmode"); got != "verify-full" {
+		t.Errorf("Expected sslmode verify-full, got %s", got)
+	}
+}
+
+func TestWithEnvAlias(t *testing.T) {
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Setenv("PGHOST", "legacy-db")
+	t.Setenv("PGPORT", "6543")
+	t.Setenv("DATABASE_PORT", "7654") // Derived name wins over the alias
+	t.Cleanup(Reset)
+	Reset()
+
+	InitViperWithOptions(nil, WithEnvAlias(map[string]string{
+		"PGHOST": "database.host",
+		"PGPORT": "database.port",
+	}))
+
+	var cfg AppConfig
+	if err := Unmarshal(&cfg); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+	if cfg.Database.Host != "legacy-db" {
+		t.Errorf("Expected PGHOST alias to set database.host, got %s", cfg.Database.Host)
+	}
+	if cfg.Database.Port != 7654 {
+		t.Errorf("Expected DATABASE_PORT to win over PGPORT, got %d", cfg.Database.Port)
+	}
+	if src := ExplainKey("database.host"); src.Kind != SourceEnv || src.Name != "PGHOST" {
+		t.Errorf("Expected database.host from env PGHOST, got %+v", src)
+	}
+}
+
+func TestSetupLogLevel(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.local.yaml")
+	if err := os.WriteFile(file, []byte("logging:\n  level: info\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Cleanup(Reset)
+	Reset()
+	InitViper(dir)
+
+	var cfg AppConfig
+	if err := Unmarshal(&cfg); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+	level := SetupLogLevel(cfg)
+	if level.Level() != zapcore.InfoLevel {
+		t.Fatalf("Expected info level, got %s", level.Level())
+	}
+
+	// Simulate the reload viper performs when the file changes
+	viper.Set("logging.level", "debug")
+	notifyWatchers()
+	if level.Level() != zapcore.DebugLevel {
+		t.Errorf("Expected debug level after reload, got %s", level.Level())
+	}
+
+	viper.Set("logging.level", "verbose")
+	notifyWatchers()
+	if level.Level() != zapcore.DebugLevel {
+		t.Errorf("Expected invalid level to be ignored, got %s", level.Level())
+	}
+}
+
+// reverseDecryptor "decrypts" ENC[...] values by reversing the wrapped text
+type reverseDecryptor struct{}
+
+func (reverseDecryptor) Decrypt(ciphertext string) (string, error) {
+	inner, ok := strings.CutPrefix(ciphertext, "ENC[")
+	if !ok || !strings.HasSuffix(inner, "]") {
+		return "", errors.New("not an encrypted value")
+	}
+	inner = strings.TrimSuffix(inner, "]")
+
+	runes := []rune(inner)
+	slices.Reverse(runes)
+	return string(runes), nil
+}
+
+func TestUnmarshalDecrypted(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig := func(password string) {
+		body := "database:\n  host: db\n  password: " + password + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "config.local.yaml"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Cleanup(Reset)
+
+	t.Run("Decrypts tagged fields", func(t *testing.T) {
+		writeConfig("ENC[terces]")
+		Reset()
+		InitViper(dir)
+
+		var cfg AppConfig
+		if err := UnmarshalDecrypted(&cfg, reverseDecryptor{}); err != nil {
+			t.Fatalf("Failed to unmarshal config: %v", err)
+		}
+		if cfg.Database.Password != "secret" {
+			t.Errorf("Expected decrypted password 'secret', got %s", cfg.Database.Password)
+		}
+		if cfg.Database.Host != "db" {
+			t.Errorf("Expected untagged host to be unchanged, got %s", cfg.Database.Host)
+		}
+	})
+
+	t.Run("Reports the failing key", func(t *testing.T) {
+		writeConfig("plaintext")
+		Reset()
+		InitViper(dir)
+
+		var cfg AppConfig
+		err := UnmarshalDecrypted(&cfg, reverseDecryptor{})
+		if err == nil || !strings.Contains(err.Error(), "database.password") {
+			t.Errorf("Expected decrypt error for database.password, got %v", err)
+		}
+	})
+}
+
+func TestInitViperMerged(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	override := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(base, []byte("service_name: base\ndatabase:\n  host: localhost\n  port: 5432\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte(`{"database": {"host": "platform-db"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(Reset)
+	Reset()
+
+	InitViperMerged(base, override)
+
+	var cfg AppConfig
+	if err := Unmarshal(&cfg); err != nil {
+		t.Fatalf("Failed to unmarshal config: %v", err)
+	}
+	if cfg.Database.Host != "platform-db" {
+		t.Errorf("Expected JSON to override database.host, got %s", cfg.Database.Host)
+	}
+	if cfg.Database.Port != 5432 || cfg.ServiceName != "base" {
+		t.Errorf("Expected keys only in YAML to be kept, got port %d, service_name %s", cfg.Database.Port, cfg.ServiceName)
+	}
+	if src := ExplainKey("database.host"); src.Kind != SourceFile || src.Name != override {
+		t.Errorf("Expected database.host from %s, got %+v", override, src)
+	}
+}
+
+func TestUnmarshalValidated(t *testing.T) {
+	type cacheConfig struct {
+		TTL     time.Duration `mapstructure:"ttl" validate:"min=1s,max=1h"`
+		Refresh time.Duration `mapstructure:"refresh"`
+	}
+	type appConfig struct {
+		Cache cacheConfig `mapstructure:"cache"`
+	}
+
+	dir := t.TempDir()
+	writeConfig := func(ttl string) {
+		body := "cache:\n  ttl: " + ttl + "\n  refresh: 24h\n"
+		if err := os.WriteFile(filepath.Join(dir, "config.local.yaml"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("RUNTIME_ENV", "local")
+	t.Cleanup(Reset)
+
+	t.Run("In bounds", func(t *testing.T) {
+		writeConfig("30m")
+		Reset()
+		InitViper(dir)
+
+		var cfg appConfig
+		if err := UnmarshalValidated(&cfg); err != nil {
+			t.Fatalf("Failed to unmarshal config: %v", err)
+		}
+		if cfg.Cache.TTL != 30*time.Minute {
+			t.Errorf("Expected TTL 30m, got %s", cfg.Cache.TTL)
+		}
+	})
+
+	for _, tc := range []struct {
+		ttl  string
+		want string
+	}{
+		{ttl: "2h", want: "config field cache.ttl is 2h0m0s, above the maximum of 1h0m0s"},
+		{ttl: "500ms", want: "config field cache.ttl is 500ms, below the minimum of 1s"},
+	} {
+		t.Run("Out of bounds "+tc.ttl, func(t *testing.T) {
+			writeConfig(tc.ttl)
+			Reset()
+			InitViper(dir)
+
+			var cfg appConfig
+			err := UnmarshalValidated(&cfg)
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("Expected error %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	t.Run("Ignores rules for other validators", func(t *testing.T) {
+		type tagged struct {
+			Name  string        `validate:"required"`
+			Port  int           `validate:"min=1,max=65535"`
+			Cache cacheConfig   `mapstructure:"cache" validate:"required"`
+			Retry time.Duration `mapstructure:"retry" validate:"required,min=1s"`
+		}
+		valid := tagged{Cache: cacheConfig{TTL: time.Minute}, Retry: time.Second}
+		if err := Validate(&valid); err != nil {
+			t.Errorf("Expected non-duration rules to be ignored, got %v", err)
+		}
+
+		// Tagged structs are still walked
+		invalid := tagged{Cache: cacheConfig{TTL: 2 * time.Hour}, Retry: time.Second}
+		want := "config field cache.ttl is 2h0m0s, above the maximum of 1h0m0s"
+		if err := Validate(&invalid); err == nil || err.Error() != want {
+			t.Errorf("Expected error %q, got %v", want, err)
+		}
+	})
+}
+
+func TestBindFlags(t *testing.T) {
+	dir := t.TempDir()
+	yaml := "database:\n  host: file-db\n  port: 5432\n  max_connections: 10\n"
+	if err := os.WriteFile(filepath.Join(dir, "config.flags.yaml"), []byte(yaml), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	Reset()
+	t.Cleanup(Reset)
+
+	t.Setenv("RUNTIME_ENV", "flags")
+	t.Setenv("DATABASE_HOST", "env-db")
+	t.Setenv("DATABASE_PORT", "6543")
+	InitViper(dir)
+
+	fs := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	fs.String("database-host", "", "database host")
+	fs.Int("database-port", 0, "database port")
+	fs.Int("database-max-connections", 0, "max connections")
+	if err := fs.Parse([]string{"--database-host=flag-db", "--database-max-connections=50"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	BindFlags(fs)
+
+	if got := viper.GetString("database.host"); got != "flag-db" {
+		t.Errorf("Expected flag to win over env and file, got %q", got)
+	}
+	if got := viper.GetInt("database.max_connections"); got != 50 {
+		t.Errorf("Expected --database-max-connections to bind database.max_connections, got %d", got)
+	}
+	if got := viper.GetInt("database.port"); got != 6543 {
+		t.Errorf("Expected unset flag not to override env, got %d", got)
+	}
+
+	if src := ExplainKey("database.host"); src.Kind != SourceFlag || src.Name != "--database-host" {
+		t.Errorf("Expected database.host from --database-host, got %+v", src)
+	}
+	if src := ExplainKey("database.port"); src.Kind != SourceEnv {
+		t.Errorf("Expected database.port from env, got %+v", src)
+	}
+}
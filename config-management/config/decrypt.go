@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// Decryptor decrypts config values stored encrypted in git (e.g. sops or KMS ciphertext)
+// The package stays crypto-agnostic; wrap your key management client in this interface
+type Decryptor interface {
+	Decrypt(ciphertext string) (string, error)
+}
+
+// UnmarshalDecrypted unmarshals the configuration like Unmarshal, then decrypts fields tagged `encrypted:"true"`
+// Empty values are left as they are, so an unset secret doesn't reach the decryptor
+func UnmarshalDecrypted(c any, d Decryptor) error {
+	if err := Unmarshal(c); err != nil {
+		return err
+	}
+	return decryptFields(reflect.ValueOf(c), "", d)
+}
+
+// decryptFields replaces encrypted string fields of v (walking nested structs, pointers and slices) in place
+func decryptFields(v reflect.Value, prefix string, d Decryptor) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return decryptFields(v.Elem(), prefix, d)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			key := joinKey(prefix, fieldKey(field))
+			if !isEncryptedField(field) {
+				if err := decryptFields(v.Field(i), key, d); err != nil {
+					return err
+				}
+				continue
+			}
+			if field.Type.Kind() != reflect.String {
+				return errors.Errorf("encrypted config field %s must be a string, got %s", key, field.Type)
+			}
+			if v.Field(i).String() == "" {
+				continue
+			}
+			plaintext, err := d.Decrypt(v.Field(i).String())
+			if err != nil {
+				return errors.Wrapf(err, "failed to decrypt %s", key)
+			}
+			v.Field(i).SetString(plaintext)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := decryptFields(v.Index(i), joinKey(prefix, fmt.Sprint(i)), d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isEncryptedField reports whether a struct field is tagged `encrypted:"true"`
+func isEncryptedField(field reflect.StructField) bool {
+	return field.Tag.Get("encrypted") == "true"
+}
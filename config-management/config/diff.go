@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldDiff describes a config field whose value differs between two configs
+type FieldDiff struct {
+	Field string // Dotted config key, e.g. database.host
+	A     any
+	B     any
+}
+
+// Diff compares two configs field by field and returns the fields that differ
+// Field names use the mapstructure keys, matching the YAML layout
+func Diff(a, b AppConfig) []FieldDiff {
+	return diffValues("", reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+// diffValues walks nested structs and compares leaf values
+func diffValues(prefix string, a, b reflect.Value) []FieldDiff {
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+		return []FieldDiff{{Field: prefix, A: a.Interface(), B: b.Interface()}}
+	}
+
+	var diffs []FieldDiff
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		diffs = append(diffs, diffValues(joinKey(prefix, fieldKey(field)), a.Field(i), b.Field(i))...)
+	}
+	return diffs
+}
+
+// fieldKey returns the config key for a struct field (mapstructure tag or lowercased name)
+func fieldKey(field reflect.StructField) string {
+	if tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ","); tag != "" && tag != "-" {
+		return tag
+	}
+	return strings.ToLower(field.Name)
+}
+
+// joinKey joins nested config keys with dots
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
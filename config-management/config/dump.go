@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces secret field values in redacted output
+const redactedValue = "[REDACTED]"
+
+// DumpYAML marshals the effective config to YAML, e.g. for a /debug/config endpoint
+// Keys follow the mapstructure tags so the output matches the config file layout
+// When redact is true, fields tagged `secret:"true"` are masked
+func DumpYAML(c any, redact bool) ([]byte, error) {
+	out, err := yaml.Marshal(toConfigMap(reflect.ValueOf(c), redact))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config to YAML")
+	}
+	return out, nil
+}
+
+// toConfigMap converts a config value into plain maps/slices keyed by config keys
+func toConfigMap(v reflect.Value, redact bool) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toConfigMap(v.Elem(), redact)
+	case reflect.Struct:
+		out := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if redact && isSecretField(field) {
+				out[fieldKey(field)] = redactedValue
+				continue
+			}
+			out[fieldKey(field)] = toConfigMap(v.Field(i), redact)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = toConfigMap(v.Index(i), redact)
+		}
+		return out
+	case reflect.Map:
+		out := map[string]any{}
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = toConfigMap(iter.Value(), redact)
+		}
+		return out
+	default:
+		return v.Interface()
+	}
+}
+
+// isSecretField reports whether a struct field is tagged `secret:"true"`
+func isSecretField(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+// ExportEnv flattens a config struct into sorted KEY=VALUE pairs, e.g. for a subprocess's cmd.Env
+// Keys use the same mapping as env var overrides (database.host -> DATABASE_HOST); prepend your
+// WithEnvPrefix prefix if the subprocess uses one. Slices of scalars are space-separated, which viper
+// splits back into a string slice; other slices get an index segment (DATABASE_REPLICAS_0_HOST).
+// Secret fields are exported unredacted, since the subprocess needs the real values
+func ExportEnv(c any) []string {
+	var env []string
+	flattenEnv("", toConfigMap(reflect.ValueOf(c), false), &env)
+	sort.Strings(env)
+	return env
+}
+
+// flattenEnv appends KEY=VALUE pairs for v under the env key prefix
+func flattenEnv(prefix string, v any, env *[]string) {
+	join := func(key string) string {
+		key = strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+		if prefix == "" {
+			return key
+		}
+		return prefix + "_" + key
+	}
+
+	switch v := v.(type) {
+	case nil:
+	case map[string]any:
+		for key, value := range v {
+			flattenEnv(join(key), value, env)
+		}
+	case []any:
+		if isScalarSlice(v) {
+			values := make([]string, len(v))
+			for i, item := range v {
+				values[i] = fmt.Sprint(item)
+			}
+			*env = append(*env, prefix+"="+strings.Join(values, " "))
+			return
+		}
+		for i, item := range v {
+			flattenEnv(join(strconv.Itoa(i)), item, env)
+		}
+	default:
+		*env = append(*env, prefix+"="+fmt.Sprint(v))
+	}
+}
+
+// isScalarSlice reports whether no item of a flattened slice is a map or slice
+func isScalarSlice(items []any) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]any, []any:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// secretConfig is a config with a secret-tagged field
+type secretConfig struct {
+	ServiceName string `mapstructure:"service_name"`
+	Database    struct {
+		Host     string `mapstructure:"host"`
+		Password string `mapstructure:"password" secret:"true"`
+	} `mapstructure:"database"`
+}
+
+func TestDumpYAML(t *testing.T) {
+	t.Run("Round-trips through viper", func(t *testing.T) {
+		cfg := AppConfig{
+			ServiceName: "config_demo",
+			Database:    DatabaseConfig{Host: "localhost", Port: 5432},
+			Redis:       RedisConfig{Addresses: []string{"localhost:6379", "localhost:6380"}},
+			Trading:     TradingConfig{MaxOrdersPerUser: 1000},
+		}
+
+		out, err := DumpYAML(cfg, false)
+		if err != nil {
+			t.Fatalf("DumpYAML failed: %v", err)
+		}
+
+		v := viper.New()
+		v.SetConfigType("yaml")
+		if err := v.ReadConfig(bytes.NewReader(out)); err != nil {
+			t.Fatalf("Dumped YAML is not valid: %v\n%s", err, out)
+		}
+		var loaded AppConfig
+		if err := v.Unmarshal(&loaded); err != nil {
+			t.Fatalf("Failed to unmarshal dumped YAML: %v", err)
+		}
+		if diffs := Diff(cfg, loaded); len(diffs) != 0 {
+			t.Errorf("Expected round-trip to preserve config, got diffs: %+v", diffs)
+		}
+	})
+
+	t.Run("Redacts secret fields", func(t *testing.T) {
+		var cfg secretConfig
+		cfg.ServiceName = "svc"
+		cfg.Database.Host = "db"
+		cfg.Database.Password = "hunter2"
+
+		out, err := DumpYAML(cfg, true)
+		if err != nil {
+			t.Fatalf("DumpYAML failed: %v", err)
+		}
+		if strings.Contains(string(out), "hunter2") {
+			t.Errorf("Expected password to be redacted, got:\n%s", out)
+		}
+
+		var dumped struct {
+			Database map[string]any `yaml:"database"`
+		}
+		if err := yaml.Unmarshal(out, &dumped); err != nil {
+			t.Fatalf("Dumped YAML is not valid: %v", err)
+		}
+		if dumped.Database["password"] != redactedValue {
+			t.Errorf("Expected password %q, got %v", redactedValue, dumped.Database["password"])
+		}
+		if dumped.Database["host"] != "db" {
+			t.Errorf("Expected host 'db', got %v", dumped.Database["host"])
+		}
+	})
+
+	t.Run("Keeps secrets when redaction is disabled", func(t *testing.T) {
+		var cfg secretConfig
+		cfg.Database.Password = "hunter2"
+
+		out, err := DumpYAML(cfg, false)
+		if err != nil {
+			t.Fatalf("DumpYAML failed: %v", err)
+		}
+		if !strings.Contains(string(out), "hunter2") {
+			t.Errorf("Expected password in unredacted output, got:\n%s", out)
+		}
+	})
+}
+
+func TestExportEnv(t *testing.T) {
+	cfg := AppConfig{
+		ServiceName: "config_demo",
+		Database:    DatabaseConfig{Host: "localhost", Port: 5432, Password: "hunter2"},
+		Redis:       RedisConfig{Addresses: []string{"localhost:6379", "localhost:6380"}},
+		Trading:     TradingConfig{MaxOrdersPerUser: 1000},
+	}
+
+	env := ExportEnv(cfg)
+	for _, want := range []string{
+		"SERVICE_NAME=config_demo",
+		"DATABASE_HOST=localhost",
+		"DATABASE_PORT=5432",
+		"DATABASE_PASSWORD=hunter2",
+		"REDIS_ADDRESSES=localhost:6379 localhost:6380",
+		"TRADING_MAX_ORDERS_PER_USER=1000",
+	} {
+		if !slices.Contains(env, want) {
+			t.Errorf("Expected %s in exported env, got %v", want, env)
+		}
+	}
+	if !slices.IsSorted(env) {
+		t.Errorf("Expected sorted output, got %v", env)
+	}
+
+	t.Run("Indexes slices of structs", func(t *testing.T) {
+		env := ExportEnv(struct {
+			Replicas []DatabaseConfig `mapstructure:"replicas"`
+		}{Replicas: []DatabaseConfig{{Host: "replica-1"}, {Host: "replica-2"}}})
+		if !slices.Contains(env, "REPLICAS_0_HOST=replica-1") || !slices.Contains(env, "REPLICAS_1_HOST=replica-2") {
+			t.Errorf("Expected indexed replica hosts, got %v", env)
+		}
+	})
+
+	t.Run("Round-trips through env overrides", func(t *testing.T) {
+		for _, kv := range env {
+			key, value, _ := strings.Cut(kv, "=")
+			t.Setenv(key, value)
+		}
+		v := viper.New()
+		v.AutomaticEnv()
+		v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+		if got := v.GetStringSlice("redis.addresses"); !slices.Equal(got, cfg.Redis.Addresses) {
+			t.Errorf("Expected redis addresses %v, got %v", cfg.Redis.Addresses, got)
+		}
+		if got := v.GetInt("trading.max_orders_per_user"); got != 1000 {
+			t.Errorf("Expected max_orders_per_user 1000, got %d", got)
+		}
+	})
+}
+
+// recordingValidator records the document it was asked to validate
+type recordingValidator struct {
+	document []byte
+}
+
+func (r *recordingValidator) Validate(schema []byte, document []byte) error {
+	r.document = document
+	return nil
+}
+
+func TestValidateSchema(t *testing.T) {
+	cfg := AppConfig{ServiceName: "config_demo", Database: DatabaseConfig{Host: "localhost", Port: 5432}}
+
+	t.Run("Requires a validator", func(t *testing.T) {
+		if err := ValidateSchema(cfg, []byte(`{}`)); !errors.Is(err, ErrNoSchemaValidator) {
+			t.Errorf("Expected ErrNoSchemaValidator, got %v", err)
+		}
+	})
+
+	t.Run("Validates the config keyed like the YAML files", func(t *testing.T) {
+		validator := &recordingValidator{}
+		DefaultSchemaValidator = validator
+		t.Cleanup(func() { DefaultSchemaValidator = nil })
+
+		if err := ValidateSchema(cfg, []byte(`{}`)); err != nil {
+			t.Fatalf("Expected valid config, got %v", err)
+		}
+		if !strings.Contains(string(validator.document), `"service_name":"config_demo"`) {
+			t.Errorf("Expected document keyed by config keys, got %s", validator.document)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// expandEnv replaces ${VAR} and $VAR tokens in string (and string list) values with environment variables
+// "$$" escapes a literal "$"; unknown variables expand to "" unless o.RequireEnvVars is set.
+// Call it before binding env vars and flags: only values loaded from files are expanded,
+// and they are merged back as config values so env vars and flags still override them
+func expandEnv(v *viper.Viper, o options) error {
+	var missing []string
+	mapping := func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	}
+
+	settings := v.AllSettings()
+	if changed := expandValue(settings, mapping); changed {
+		if err := v.MergeConfigMap(settings); err != nil {
+			return errors.Wrap(err, "can't merge expanded config")
+		}
+	}
+
+	if o.RequireEnvVars && len(missing) > 0 {
+		return errors.Errorf("config references unset environment variables: %v", missing)
+	}
+	return nil
+}
+
+// expandValue expands strings in value in place, walking nested maps and lists
+// Returns whether anything changed
+func expandValue(value any, mapping func(string) string) bool {
+	changed := false
+	switch value := value.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if s, ok := item.(string); ok {
+				if expanded := os.Expand(s, mapping); expanded != s {
+					value[k] = expanded
+					changed = true
+				}
+				continue
+			}
+			changed = expandValue(item, mapping) || changed
+		}
+	case []any:
+		for i, item := range value {
+			if s, ok := item.(string); ok {
+				if expanded := os.Expand(s, mapping); expanded != s {
+					value[i] = expanded
+					changed = true
+				}
+				continue
+			}
+			changed = expandValue(item, mapping) || changed
+		}
+	}
+	return changed
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Flags bound by BindFlags, by config key, so ExplainKey can report them
+var (
+	boundFlags   = map[string]*pflag.Flag{}
+	boundFlagsMu sync.RWMutex
+)
+
+// BindFlags lets command-line flags override config: precedence is flags > env > files > defaults
+// A flag binds to the loaded key whose dots and underscores become dashes (--database-host -> database.host,
+// --database-max-connections -> database.max_connections), otherwise to its name with dashes as dots.
+// Call it after InitViper so loaded keys are known; flags that aren't set only act as defaults
+func BindFlags(fs *pflag.FlagSet) {
+	keys := map[string]string{}
+	for _, key := range viper.AllKeys() {
+		keys[flagName(key)] = key
+	}
+
+	boundFlagsMu.Lock()
+	defer boundFlagsMu.Unlock()
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.ToLower(f.Name)
+		key, ok := keys[name]
+		if !ok {
+			key = strings.ReplaceAll(name, "-", ".")
+		}
+		if err := viper.BindPFlag(key, f); err != nil {
+			zap.L().Fatal("can't bind flag", zap.String("flag", f.Name), zap.Error(err))
+		}
+		boundFlags[key] = f
+	})
+}
+
+// flagName returns the flag name matching a config key: database.max_connections -> database-max-connections
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// changedFlag returns the flag bound to key if it was set on the command line
+func changedFlag(key string) *pflag.Flag {
+	boundFlagsMu.RLock()
+	defer boundFlagsMu.RUnlock()
+	if f, ok := boundFlags[key]; ok && f.Changed {
+		return f
+	}
+	return nil
+}
+
+// resetBoundFlags forgets the flags bound by BindFlags
+func resetBoundFlags() {
+	boundFlagsMu.Lock()
+	defer boundFlagsMu.Unlock()
+	boundFlags = map[string]*pflag.Flag{}
+}
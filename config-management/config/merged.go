@@ -0,0 +1,48 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// InitViperMerged initializes the global Viper from files merged in the given order
+// Later files override earlier ones regardless of format (type detected by extension: yaml, json, toml, ...),
+// e.g. a repo config.yaml followed by a platform-injected config.json. Relative paths are resolved
+// against Root; env var overrides apply on top like with InitViper
+func InitViperMerged(files ...string) {
+	keys := keyFiles{}
+	if err := loadMerged(viper.GetViper(), files, keys); err != nil {
+		zap.L().Fatal("can't init config", zap.Error(err))
+	}
+	setKeyFiles(keys, "", nil)
+}
+
+// loadMerged reads each file into its own viper and merges its settings into v in order
+func loadMerged(v *viper.Viper, files []string, keys keyFiles) error {
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = path.Join(Root, file)
+		}
+
+		fv := viper.New()
+		fv.SetConfigFile(file)
+		if err := fv.ReadInConfig(); err != nil {
+			return errors.Wrapf(err, "can't load config file: %s", file)
+		}
+		if err := v.MergeConfigMap(fv.AllSettings()); err != nil {
+			return errors.Wrapf(err, "can't merge config file: %s", file)
+		}
+		if err := keys.record(file); err != nil {
+			return err
+		}
+	}
+
+	v.AutomaticEnv()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	return nil
+}
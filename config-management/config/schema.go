@@ -0,0 +1,35 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaValidator validates a JSON document against a JSON Schema
+// ValidateSchema depends on this interface so the schema library can be swapped
+type SchemaValidator interface {
+	Validate(schema []byte, document []byte) error
+}
+
+// DefaultSchemaValidator is the validator used by ValidateSchema
+// None is set by default; use schemavalidator.Validator{} or another implementation
+var DefaultSchemaValidator SchemaValidator
+
+// ErrNoSchemaValidator is returned by ValidateSchema when DefaultSchemaValidator is not set
+var ErrNoSchemaValidator = errors.New("no schema validator configured; set config.DefaultSchemaValidator")
+
+// ValidateSchema checks a config struct against a JSON Schema, e.g. an org-wide config contract
+// The config is converted to JSON using the config keys (mapstructure tags), so the schema
+// describes the same layout as the YAML files. Errors name the offending keys (database.port: ...)
+func ValidateSchema(c any, schema []byte) error {
+	if DefaultSchemaValidator == nil {
+		return ErrNoSchemaValidator
+	}
+	document, err := json.Marshal(toConfigMap(reflect.ValueOf(c), false))
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal config to JSON")
+	}
+	return DefaultSchemaValidator.Validate(schema, document)
+}
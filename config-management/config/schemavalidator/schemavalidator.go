@@ -0,0 +1,65 @@
+// Package schemavalidator implements config.SchemaValidator with santhosh-tekuri/jsonschema
+// Install it with config.DefaultSchemaValidator = schemavalidator.Validator{}; it lives outside config
+// so services that don't validate against a schema don't pull in the library
+package schemavalidator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"config-management/config"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Validator validates config documents with santhosh-tekuri/jsonschema
+type Validator struct{}
+
+var _ config.SchemaValidator = Validator{}
+
+// schemaURL is the placeholder resource name the schema is compiled under
+const schemaURL = "config.schema.json"
+
+// Validate checks document against schema; violations are reported as "key: message"
+func (Validator) Validate(schema []byte, document []byte) error {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(schema)); err != nil {
+		return errors.Wrap(err, "invalid JSON schema")
+	}
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return errors.Wrap(err, "invalid JSON schema")
+	}
+
+	var doc any
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return errors.Wrap(err, "invalid JSON document")
+	}
+
+	var verr *jsonschema.ValidationError
+	if err := compiled.Validate(doc); errors.As(err, &verr) {
+		return errors.Errorf("config does not match schema: %s", strings.Join(violations(verr), "; "))
+	} else if err != nil {
+		return errors.Wrap(err, "failed to validate config")
+	}
+	return nil
+}
+
+// violations returns "key: message" for each leaf validation error
+func violations(verr *jsonschema.ValidationError) []string {
+	if len(verr.Causes) == 0 {
+		key := strings.ReplaceAll(strings.TrimPrefix(verr.InstanceLocation, "/"), "/", ".")
+		if key == "" {
+			key = "(root)"
+		}
+		return []string{key + ": " + verr.Message}
+	}
+
+	var all []string
+	for _, cause := range verr.Causes {
+		all = append(all, violations(cause)...)
+	}
+	return all
+}
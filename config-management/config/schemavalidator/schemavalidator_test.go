@@ -0,0 +1,53 @@
+package schemavalidator
+
+import (
+	"strings"
+	"testing"
+
+	"config-management/config"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := []byte(`{
+		"type": "object",
+		"required": ["service_name", "database"],
+		"properties": {
+			"service_name": {"type": "string", "minLength": 1},
+			"database": {
+				"type": "object",
+				"properties": {
+					"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+				}
+			}
+		}
+	}`)
+	config.DefaultSchemaValidator = Validator{}
+	t.Cleanup(func() { config.DefaultSchemaValidator = nil })
+
+	cfg := config.AppConfig{ServiceName: "config_demo", Database: config.DatabaseConfig{Host: "localhost", Port: 5432}}
+
+	t.Run("Valid config passes", func(t *testing.T) {
+		if err := config.ValidateSchema(cfg, schema); err != nil {
+			t.Errorf("Expected valid config, got %v", err)
+		}
+	})
+
+	t.Run("Out-of-range value names the key", func(t *testing.T) {
+		invalid := cfg
+		invalid.Database.Port = 70000
+
+		err := config.ValidateSchema(invalid, schema)
+		if err == nil {
+			t.Fatal("Expected schema violation")
+		}
+		if !strings.Contains(err.Error(), "database.port:") {
+			t.Errorf("Expected error pointing at database.port, got %v", err)
+		}
+	})
+
+	t.Run("Invalid schema is reported", func(t *testing.T) {
+		if err := config.ValidateSchema(cfg, []byte(`{"type": 1`)); err == nil {
+			t.Error("Expected error for malformed schema")
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// WithSecretDir merges Kubernetes-style mounted secrets from dir: each file is one key and its content the value
+// File names map to dotted keys by replacing underscores with dots (database_password -> database.password)
+// Secrets override config files but not env vars
+func WithSecretDir(dir string) Option {
+	return func(o *options) {
+		o.SecretDir = dir
+	}
+}
+
+// InitViperWithSecretDir initializes Viper like InitViper and merges the secrets mounted in dir
+func InitViperWithSecretDir(dir string, configPaths ...string) {
+	InitViperWithOptions(configPaths, WithSecretDir(dir))
+}
+
+// loadSecretDir merges every secret file in dir into v as config values
+// Hidden entries (e.g. the ..data symlink Kubernetes creates) and directories are skipped
+func loadSecretDir(v *viper.Viper, dir string, files keyFiles) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrapf(err, "can't read secret dir: %s", dir)
+	}
+
+	secrets := map[string]any{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		// Stat follows symlinks, which is how Kubernetes mounts secret keys
+		info, err := os.Stat(file)
+		if err != nil {
+			return errors.Wrapf(err, "can't stat secret file: %s", file)
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return errors.Wrapf(err, "can't read secret file: %s", file)
+		}
+		key := strings.ToLower(strings.ReplaceAll(entry.Name(), "_", "."))
+		setNested(secrets, strings.Split(key, "."), strings.TrimRight(string(content), "\r\n"))
+		if files != nil {
+			files[key] = file
+		}
+	}
+
+	// Merge as config (not viper.Set) so env vars keep precedence
+	return errors.Wrap(v.MergeConfigMap(secrets), "can't merge secrets")
+}
+
+// setNested sets value at the path of keys in m, creating intermediate maps
+func setNested(m map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
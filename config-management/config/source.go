@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// SourceKind identifies where a config value came from
+type SourceKind string
+
+const (
+	SourceUnset   SourceKind = "unset"   // Key is not set anywhere
+	SourceDefault SourceKind = "default" // Value set via viper defaults or viper.Set
+	SourceFile    SourceKind = "file"    // Value loaded from a config file
+	SourceEnv     SourceKind = "env"     // Value overridden by an environment variable
+	SourceFlag    SourceKind = "flag"    // Value overridden by a command-line flag (BindFlags)
+)
+
+// KeySource describes where the effective value of a config key came from
+type KeySource struct {
+	Kind SourceKind
+	Name string // Config file path, env var name or flag (empty for defaults)
+}
+
+// keyFiles maps config keys to the file they were last loaded from (later files override earlier)
+type keyFiles map[string]string
+
+// record marks every key defined in file as coming from it
+func (f keyFiles) record(file string) error {
+	if f == nil || file == "" {
+		return nil
+	}
+
+	fv := viper.New()
+	fv.SetConfigFile(file)
+	if err := fv.ReadInConfig(); err != nil {
+		return errors.Wrapf(err, "can't read config file for provenance: %s", file)
+	}
+	for _, key := range fv.AllKeys() {
+		f[key] = file
+	}
+	return nil
+}
+
+// Provenance of the keys loaded by InitViper
+var (
+	loadedKeyFiles   = keyFiles{}
+	loadedEnvPrefix  string
+	loadedEnvAliases map[string]string
+	loadedKeyFilesMu sync.RWMutex
+)
+
+// setKeyFiles replaces the recorded provenance after InitViper loads the global config
+func setKeyFiles(files keyFiles, envPrefix string, envAliases map[string]string) {
+	loadedKeyFilesMu.Lock()
+	defer loadedKeyFilesMu.Unlock()
+	loadedKeyFiles = files
+	loadedEnvPrefix = envPrefix
+	loadedEnvAliases = envAliases
+}
+
+// ExplainKey reports where the effective value of a key loaded by InitViper came from:
+// a command-line flag, an env var, a config file (which one) or a default
+// Useful for debugging "where did this value come from"
+func ExplainKey(key string) KeySource {
+	key = strings.ToLower(key)
+
+	loadedKeyFilesMu.RLock()
+	file, fromFile := loadedKeyFiles[key]
+	envPrefix := loadedEnvPrefix
+	envAliases := loadedEnvAliases
+	loadedKeyFilesMu.RUnlock()
+
+	if f := changedFlag(key); f != nil {
+		return KeySource{Kind: SourceFlag, Name: "--" + f.Name}
+	}
+
+	envVar := strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	if envPrefix != "" {
+		envVar = strings.ToUpper(envPrefix) + "_" + envVar
+	}
+	if _, ok := os.LookupEnv(envVar); ok {
+		return KeySource{Kind: SourceEnv, Name: envVar}
+	}
+	for alias, aliasKey := range envAliases {
+		if _, ok := os.LookupEnv(alias); ok && strings.ToLower(aliasKey) == key {
+			return KeySource{Kind: SourceEnv, Name: alias}
+		}
+	}
+
+	if fromFile {
+		return KeySource{Kind: SourceFile, Name: file}
+	}
+
+	if viper.IsSet(key) {
+		return KeySource{Kind: SourceDefault}
+	}
+	return KeySource{Kind: SourceUnset}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// durationType is the type validate rules apply to
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// durationRule bounds a duration field by the duration in the rule's parameter
+type durationRule struct {
+	ok      func(value, bound time.Duration) bool
+	failure string // e.g. "below the minimum"
+}
+
+// durationRules are the validate tag rules supported on time.Duration fields
+var durationRules = map[string]durationRule{
+	"min": {ok: func(value, bound time.Duration) bool { return value >= bound }, failure: "below the minimum"},
+	"max": {ok: func(value, bound time.Duration) bool { return value <= bound }, failure: "above the maximum"},
+}
+
+// UnmarshalValidated unmarshals the configuration like Unmarshal, then checks it with Validate
+func UnmarshalValidated(c any) error {
+	if err := Unmarshal(c); err != nil {
+		return err
+	}
+	return Validate(c)
+}
+
+// Validate checks time.Duration fields tagged with bounds, e.g. `validate:"min=1s,max=1h"` for a cache TTL
+// Bounds are Go duration strings; the error names the config key, the value and the violated bound.
+// Other rules and tags on other field types are ignored, so they can be checked by a general validator
+func Validate(c any) error {
+	return validateFields(reflect.ValueOf(c), "")
+}
+
+// validateFields checks tagged fields of v, walking nested structs, pointers and slices
+func validateFields(v reflect.Value, prefix string) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return validateFields(v.Elem(), prefix)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			key := joinKey(prefix, fieldKey(field))
+			if field.Type != durationType {
+				// Tags on other fields are left to general validators (e.g. `validate:"required"`)
+				if err := validateFields(v.Field(i), key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := validateDuration(key, time.Duration(v.Field(i).Int()), field.Tag.Get("validate")); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateFields(v.Index(i), joinKey(prefix, fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateDuration applies the min/max rules of a validate tag to value
+// Other rules (e.g. required) are ignored so the tag can be shared with general validators
+func validateDuration(key string, value time.Duration, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, r := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(r), "=")
+		rule, ok := durationRules[name]
+		if !ok {
+			continue
+		}
+		bound, err := time.ParseDuration(param)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s bound on config field %s", name, key)
+		}
+		if !rule.ok(value, bound) {
+			return errors.Errorf("config field %s is %s, %s of %s", key, value, rule.failure, bound)
+		}
+	}
+	return nil
+}
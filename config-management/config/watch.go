@@ -0,0 +1,81 @@
+package config
+
+import (
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	watchMu  sync.Mutex
+	watchers []func(AppConfig)
+	watching bool // viper.WatchConfig started on the current global viper
+)
+
+// Watch calls onChange with the reloaded AppConfig whenever the main config file changes
+// Starts viper's file watcher on first use; InitViper must have loaded a config file.
+// Note: viper re-reads only the main file on change, additional_configs are not reloaded
+func Watch(onChange func(AppConfig)) {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+
+	watchers = append(watchers, onChange)
+	if watching {
+		return
+	}
+	watching = true
+	viper.OnConfigChange(func(fsnotify.Event) { notifyWatchers() })
+	viper.WatchConfig()
+}
+
+// notifyWatchers unmarshals the global config and passes it to every Watch callback
+func notifyWatchers() {
+	var cfg AppConfig
+	if err := Unmarshal(&cfg); err != nil {
+		zap.L().Warn("can't reload config", zap.Error(err))
+		return
+	}
+
+	watchMu.Lock()
+	fns := append([]func(AppConfig){}, watchers...)
+	watchMu.Unlock()
+	for _, fn := range fns {
+		fn(cfg)
+	}
+}
+
+// resetWatchers drops Watch callbacks so the next Watch starts watching the current global viper
+func resetWatchers() {
+	watchMu.Lock()
+	defer watchMu.Unlock()
+	watchers = nil
+	watching = false
+}
+
+// SetupLogLevel returns a zap.AtomicLevel set from logging.level that follows config reloads (see Watch)
+// Build the logger with it (zap.Config.Level) to switch e.g. to debug in production without a restart.
+// Invalid levels are logged and ignored, keeping the current level (info initially)
+func SetupLogLevel(cfg AppConfig) zap.AtomicLevel {
+	level := zap.NewAtomicLevel()
+	applyLogLevel(level, cfg.Logging.Level)
+	Watch(func(cfg AppConfig) {
+		applyLogLevel(level, cfg.Logging.Level)
+	})
+	return level
+}
+
+// applyLogLevel sets level from its name, leaving it unchanged when name is empty or invalid
+func applyLogLevel(level zap.AtomicLevel, name string) {
+	if name == "" {
+		return
+	}
+	parsed, err := zapcore.ParseLevel(name)
+	if err != nil {
+		zap.L().Warn("invalid logging.level, keeping current level", zap.String("level", level.String()), zap.Error(err))
+		return
+	}
+	level.SetLevel(parsed)
+}
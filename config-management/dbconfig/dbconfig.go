@@ -0,0 +1,35 @@
+// Package dbconfig converts a loaded config.DatabaseConfig into the db-testing and sql-migration configs
+// It lives in its own module so the config package doesn't depend on the database patterns
+package dbconfig
+
+import (
+	"config-management/config"
+
+	dbtesting "db-testing"
+	migration "sql-migration"
+)
+
+// ToTestingConfig returns the db-testing connection config for the database
+// With EnvTest, Name is the base database the isolated test databases are created from
+func ToTestingConfig(c config.DatabaseConfig) dbtesting.Config {
+	return dbtesting.Config{
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Password: c.Password,
+		Database: c.Name,
+		SSLMode:  c.SSLMode,
+	}
+}
+
+// ToMigrationConfig returns the sql-migration connection config for the database
+func ToMigrationConfig(c config.DatabaseConfig) migration.Config {
+	return migration.Config{
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Password: c.Password,
+		Database: c.Name,
+		SSLMode:  c.SSLMode,
+	}
+}
@@ -0,0 +1,43 @@
+package dbconfig
+
+import (
+	"strings"
+	"testing"
+
+	"config-management/config"
+)
+
+func TestDatabaseConfigBridges(t *testing.T) {
+	db := config.AppConfig{Database: config.DatabaseConfig{
+		Host:     "db.internal",
+		Port:     6543,
+		User:     "app",
+		Password: "secret",
+		Name:     "orders",
+		SSLMode:  "require",
+	}}.Database
+
+	testingConfig := ToTestingConfig(db)
+	if testingConfig.Host != "db.internal" || testingConfig.Port != 6543 || testingConfig.User != "app" ||
+		testingConfig.Password != "secret" || testingConfig.Database != "orders" || testingConfig.SSLMode != "require" {
+		t.Errorf("Unexpected db-testing config: %+v", testingConfig)
+	}
+	if got := testingConfig.ConnString(); !strings.Contains(got, "port=6543") || !strings.Contains(got, "sslmode=require") {
+		t.Errorf("Expected port and sslmode in db-testing conn string, got %s", got)
+	}
+
+	migrationConfig := ToMigrationConfig(db)
+	if migrationConfig.Host != "db.internal" || migrationConfig.Port != 6543 || migrationConfig.User != "app" ||
+		migrationConfig.Password != "secret" || migrationConfig.Database != "orders" || migrationConfig.SSLMode != "require" {
+		t.Errorf("Unexpected migration config: %+v", migrationConfig)
+	}
+	if got := migrationConfig.ConnString(); !strings.Contains(got, "port=6543") || !strings.Contains(got, "sslmode=require") {
+		t.Errorf("Expected port and sslmode in migration conn string, got %s", got)
+	}
+
+	// Unset ssl_mode keeps each package's default
+	db.SSLMode = ""
+	if got := ToMigrationConfig(db).ConnString(); !strings.Contains(got, "sslmode=disable") {
+		t.Errorf("Expected default sslmode=disable, got %s", got)
+	}
+}
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Supported database dialects
+const (
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+// dialect returns the configured dialect, defaulting to Postgres
+func (c *CodeGenerator) dialect() string {
+	if c.Dialect == "" {
+		return DialectPostgres
+	}
+	return c.Dialect
+}
+
+// dialector opens dsn with the gorm driver for the configured dialect
+func (c *CodeGenerator) dialector(dsn string) (gorm.Dialector, error) {
+	switch c.dialect() {
+	case DialectPostgres:
+		return postgres.Open(dsn), nil
+	case DialectMySQL:
+		return mysql.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported dialect: %s", c.Dialect)
+	}
+}
+
+// tempConnString returns the connection string for the temporary database
+func (c *CodeGenerator) tempConnString() (string, error) {
+	switch c.dialect() {
+	case DialectMySQL:
+		cfg, err := mysqldriver.ParseDSN(c.ConnString)
+		if err != nil {
+			return "", fmt.Errorf("invalid mysql dsn: %v", err)
+		}
+		cfg.DBName = c.TempDB
+		cfg.ParseTime = true
+		return cfg.FormatDSN(), nil
+	default:
+		return fmt.Sprintf("host=localhost user=postgres password=password dbname=%s port=5432 sslmode=disable", c.TempDB), nil
+	}
+}
+
+// quoteIdent quotes a database name for DDL, which can't take bind parameters
+// Postgres uses double quotes and MySQL backticks; embedded quote characters are doubled
+func (c *CodeGenerator) quoteIdent(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("invalid database name %q", name)
+	}
+	quote := `"`
+	if c.dialect() == DialectMySQL {
+		quote = "`"
+	}
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote, nil
+}
+
+// dataTypeMap returns column type overrides for the gen data-type map (nil keeps gen's defaults)
+func dataTypeMap(dialect string) map[string]func(columnType gorm.ColumnType) string {
+	if dialect != DialectMySQL {
+		return nil
+	}
+	return map[string]func(columnType gorm.ColumnType) string{
+		// MySQL has no real boolean: BOOL columns are tinyint(1)
+		"tinyint": func(columnType gorm.ColumnType) string {
+			if isBooleanLike(columnType, "tinyint(1)") {
+				return "bool"
+			}
+			return "int8"
+		},
+		"bit": func(columnType gorm.ColumnType) string {
+			if isBooleanLike(columnType, "bit(1)") {
+				return "bool"
+			}
+			return "[]byte"
+		},
+	}
+}
+
+// isBooleanLike reports whether the full column type (e.g. "tinyint(1) unsigned") starts with prefix
+func isBooleanLike(columnType gorm.ColumnType, prefix string) bool {
+	fullType, ok := columnType.ColumnType()
+	return ok && strings.HasPrefix(strings.ToLower(strings.TrimSpace(fullType)), prefix)
+}
@@ -0,0 +1,97 @@
+package generator
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// columnType lets fakeColumn embed gorm.ColumnType without clashing with its ColumnType method
+type columnType = gorm.ColumnType
+
+// fakeColumn reports a fixed full column type; other gorm.ColumnType methods are unused
+type fakeColumn struct {
+	columnType
+	fullType string
+}
+
+func (f fakeColumn) ColumnType() (string, bool) {
+	return f.fullType, true
+}
+
+func TestMySQLDataTypeMap(t *testing.T) {
+	typeMap := dataTypeMap(DialectMySQL)
+
+	tests := []struct {
+		dataType string
+		fullType string
+		want     string
+	}{
+		{"tinyint", "tinyint(1)", "bool"},
+		{"tinyint", "TINYINT(1) UNSIGNED", "bool"},
+		{"tinyint", "tinyint(4)", "int8"},
+		{"tinyint", "tinyint", "int8"},
+		{"bit", "bit(1)", "bool"},
+		{"bit", "bit(8)", "[]byte"},
+	}
+	for _, tt := range tests {
+		mapping, ok := typeMap[tt.dataType]
+		if !ok {
+			t.Fatalf("Expected mapping for %s", tt.dataType)
+		}
+		if got := mapping(fakeColumn{fullType: tt.fullType}); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.fullType, tt.want, got)
+		}
+	}
+
+	if dataTypeMap(DialectPostgres) != nil {
+		t.Error("Expected Postgres to keep gen's default type mapping")
+	}
+}
+
+func TestTempConnString(t *testing.T) {
+	gen := &CodeGenerator{
+		ConnString: "root:password@tcp(localhost:3306)/mysql",
+		TempDB:     "gopher_patterns_gen",
+		Dialect:    DialectMySQL,
+	}
+	dsn, err := gen.tempConnString()
+	if err != nil {
+		t.Fatalf("Failed to build temp dsn: %v", err)
+	}
+	if want := "root:password@tcp(localhost:3306)/gopher_patterns_gen?parseTime=true"; dsn != want {
+		t.Errorf("Expected %s, got %s", want, dsn)
+	}
+
+	gen.Dialect = "oracle"
+	if _, err := gen.dialector(gen.ConnString); err == nil {
+		t.Error("Expected error for unsupported dialect")
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	tests := []struct {
+		dialect string
+		name    string
+		want    string
+	}{
+		{DialectPostgres, "gen_db", `"gen_db"`},
+		{DialectPostgres, `gen"; DROP DATABASE prod; --`, `"gen""; DROP DATABASE prod; --"`},
+		{DialectMySQL, "gen_db", "`gen_db`"},
+		{DialectMySQL, "gen`db", "`gen``db`"},
+	}
+	for _, tt := range tests {
+		c := &CodeGenerator{Dialect: tt.dialect}
+		got, err := c.quoteIdent(tt.name)
+		if err != nil {
+			t.Fatalf("%s %q: unexpected error: %v", tt.dialect, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s %q: expected %s, got %s", tt.dialect, tt.name, tt.want, got)
+		}
+	}
+
+	if _, err := (&CodeGenerator{}).quoteIdent(""); err == nil {
+		t.Error("Expected an empty name to be rejected")
+	}
+}
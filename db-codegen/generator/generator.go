@@ -2,9 +2,11 @@ package generator
 
 import (
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"os"
+	"path/filepath"
 
-	"gorm.io/driver/postgres"
 	"gorm.io/gen"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -13,45 +15,92 @@ import (
 type CodeGenerator struct {
 	ConnString string
 	TempDB     string
+	OutPath    string // Query package output directory (default "query"); models go to a sibling "model" directory
+	DryRun     bool   // Run the full pipeline but write into a temp directory instead of OutPath
+	Dialect    string // DialectPostgres (default) or DialectMySQL; selects the driver, schema DDL and type mapping
+	SchemaSQL  string // DDL run in the temp DB instead of the built-in users/orders tables (e.g. a schema dump); models are generated for every table it creates
 }
 
 func (c *CodeGenerator) Run() error {
+	_, err := c.Generate()
+	return err
+}
+
+// Generate runs code generation and returns the paths of the generated files
+// In dry-run mode the files are written to a fresh temp directory (left for the caller to diff and remove,
+// or removed if generation fails) and OutPath is not touched
+func (c *CodeGenerator) Generate() ([]string, error) {
 	slog.Info("Starting database code generation")
 
+	outPath := c.OutPath
+	if outPath == "" {
+		outPath = "query"
+	}
+	succeeded := false
+	if c.DryRun {
+		tempDir, err := os.MkdirTemp("", "db-codegen-")
+		if err != nil {
+			return nil, fmt.Errorf("could not create dry-run dir: %v", err)
+		}
+		// Only a successful run leaves the dir behind for the caller
+		defer func() {
+			if !succeeded {
+				os.RemoveAll(tempDir)
+			}
+		}()
+		outPath = filepath.Join(tempDir, filepath.Base(outPath))
+		slog.Info("Dry run: writing generated code to temp directory", "dir", tempDir)
+	}
+
 	// Connect to admin database
-	gormDB, err := gorm.Open(postgres.Open(c.ConnString), &gorm.Config{
+	adminDialector, err := c.dialector(c.ConnString)
+	if err != nil {
+		return nil, err
+	}
+	gormDB, err := gorm.Open(adminDialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
-		return fmt.Errorf("could not connect to db: %v", err)
+		return nil, fmt.Errorf("could not connect to db: %v", err)
 	}
 
 	// Drop and create temporary database
-	if err := gormDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", c.TempDB)).Error; err != nil {
+	tempDBName, err := c.quoteIdent(c.TempDB)
+	if err != nil {
+		return nil, err
+	}
+	if err := gormDB.Exec("DROP DATABASE IF EXISTS " + tempDBName).Error; err != nil {
 		slog.Warn("drop database error", "error", err)
 	}
-	if err := gormDB.Exec(fmt.Sprintf("CREATE DATABASE %s", c.TempDB)).Error; err != nil {
-		return fmt.Errorf("create database error: %v", err)
+	if err := gormDB.Exec("CREATE DATABASE " + tempDBName).Error; err != nil {
+		return nil, fmt.Errorf("create database error: %v", err)
 	}
-	defer gormDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", c.TempDB))
+	defer gormDB.Exec("DROP DATABASE IF EXISTS " + tempDBName)
 
 	// Connect to temporary database
-	tempConnString := fmt.Sprintf("host=localhost user=postgres password=password dbname=%s port=5432 sslmode=disable", c.TempDB)
-	tempDB, err := gorm.Open(postgres.Open(tempConnString), &gorm.Config{
+	tempConnString, err := c.tempConnString()
+	if err != nil {
+		return nil, err
+	}
+	tempDialector, err := c.dialector(tempConnString)
+	if err != nil {
+		return nil, err
+	}
+	tempDB, err := gorm.Open(tempDialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
-		return fmt.Errorf("could not open temp gorm db: %v", err)
+		return nil, fmt.Errorf("could not open temp gorm db: %v", err)
 	}
 
 	// Create database schema
 	if err := c.createSchema(tempDB); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Generate code
-	if err := c.generateCode(tempDB); err != nil {
-		return err
+	if err := c.generateCode(tempDB, outPath); err != nil {
+		return nil, err
 	}
 
 	slog.Info("Code generation completed")
@@ -61,11 +110,28 @@ func (c *CodeGenerator) Run() error {
 		sqlDB.Close()
 	}
 
-	return nil
+	files, err := generatedFiles(outPath)
+	if err != nil {
+		return nil, err
+	}
+	succeeded = true
+	return files, nil
 }
 
-// createSchema creates dummy tables for code generation only. In real projects, you should use your actual database schema.
+// createSchema runs SchemaSQL when set, otherwise creates dummy tables for code generation only
+// MySQL runs multi-statement DDL only with multiStatements=true in ConnString
 func (c *CodeGenerator) createSchema(db *gorm.DB) error {
+	if c.SchemaSQL != "" {
+		if err := db.Exec(c.SchemaSQL).Error; err != nil {
+			return fmt.Errorf("failed to run schema SQL: %v", err)
+		}
+		return nil
+	}
+
+	if c.dialect() == DialectMySQL {
+		return c.createMySQLSchema(db)
+	}
+
 	if err := db.Exec(`
 		CREATE TABLE users (
 			id BIGSERIAL PRIMARY KEY,
@@ -96,9 +162,42 @@ func (c *CodeGenerator) createSchema(db *gorm.DB) error {
 	return nil
 }
 
-func (c *CodeGenerator) generateCode(db *gorm.DB) error {
+// createMySQLSchema creates the same dummy tables with MySQL types
+func (c *CodeGenerator) createMySQLSchema(db *gorm.DB) error {
+	if err := db.Exec(`
+		CREATE TABLE users (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			email VARCHAR(100) UNIQUE NOT NULL,
+			active TINYINT(1) NOT NULL DEFAULT 1,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create users table: %v", err)
+	}
+
+	if err := db.Exec(`
+		CREATE TABLE orders (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			user_id BIGINT NOT NULL,
+			product VARCHAR(100) NOT NULL,
+			quantity INT NOT NULL DEFAULT 1,
+			price DECIMAL(10,2) NOT NULL,
+			status VARCHAR(20) DEFAULT 'pending',
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error; err != nil {
+		return fmt.Errorf("failed to create orders table: %v", err)
+	}
+
+	return nil
+}
+
+func (c *CodeGenerator) generateCode(db *gorm.DB, outPath string) error {
 	var genConfig = gen.Config{
-		OutPath:           "query",
+		OutPath:           outPath,
 		OutFile:           "gen.go",
 		FieldSignable:     false,
 		FieldWithIndexTag: false,
@@ -106,14 +205,41 @@ func (c *CodeGenerator) generateCode(db *gorm.DB) error {
 		Mode:              gen.WithoutContext | gen.WithDefaultQuery | gen.WithQueryInterface,
 	}
 
+	if typeMap := dataTypeMap(c.dialect()); typeMap != nil {
+		genConfig.WithDataTypeMap(typeMap)
+	}
+
 	g := gen.NewGenerator(genConfig)
 	g.UseDB(db)
 
-	user := g.GenerateModel("users")
-	order := g.GenerateModel("orders")
-
-	g.ApplyBasic(user, order)
+	if c.SchemaSQL != "" {
+		g.ApplyBasic(g.GenerateAllTable()...)
+	} else {
+		user := g.GenerateModel("users")
+		order := g.GenerateModel("orders")
+		g.ApplyBasic(user, order)
+	}
 	g.Execute()
 
 	return nil
 }
+
+// generatedFiles lists the Go files in the query output directory and its sibling model directory
+func generatedFiles(outPath string) ([]string, error) {
+	var files []string
+	for _, dir := range []string{outPath, filepath.Join(filepath.Dir(outPath), "model")} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ".go" {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not list generated files: %v", err)
+		}
+	}
+	return files, nil
+}
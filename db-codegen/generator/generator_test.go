@@ -0,0 +1,157 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	outDir := t.TempDir()
+	gen := &CodeGenerator{
+		ConnString: "host=localhost user=postgres password=password dbname=postgres port=5432 sslmode=disable",
+		TempDB:     "gopher_patterns_gen_dry_run",
+		OutPath:    filepath.Join(outDir, "query"),
+		DryRun:     true,
+	}
+
+	files, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected dry run to list generated files")
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(filepath.Dir(files[0]))) })
+
+	var names []string
+	for _, f := range files {
+		if strings.HasPrefix(f, outDir) {
+			t.Errorf("Dry run file %s is inside the configured output", f)
+		}
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("Expected dry run file %s to exist: %v", f, err)
+		}
+		names = append(names, filepath.Base(filepath.Dir(f))+"/"+filepath.Base(f))
+	}
+	for _, want := range []string{"query/gen.go", "query/users.gen.go", "model/users.gen.go"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("Expected dry run to produce %s, got %v", want, names)
+		}
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("Failed to read output dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files written to output dir in dry-run mode, found %d entries", len(entries))
+	}
+}
+
+func TestDryRunRemovesTempDirOnError(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	gen := &CodeGenerator{
+		ConnString: "host=localhost user=postgres password=password dbname=postgres port=5432 sslmode=disable",
+		TempDB:     "gopher_patterns_gen_dry_run_error",
+		Dialect:    "oracle",
+		DryRun:     true,
+	}
+	if _, err := gen.Generate(); err == nil {
+		t.Fatal("Expected an unsupported dialect to fail")
+	}
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected the dry-run dir to be removed after a failure, found %d entries", len(entries))
+	}
+}
+
+func TestSchemaSQL(t *testing.T) {
+	gen := &CodeGenerator{
+		ConnString: "host=localhost user=postgres password=password dbname=postgres port=5432 sslmode=disable",
+		TempDB:     "gopher_patterns_gen_schema_sql",
+		OutPath:    filepath.Join(t.TempDir(), "query"),
+		DryRun:     true,
+		SchemaSQL: `
+			CREATE TABLE products (
+				id BIGSERIAL PRIMARY KEY,
+				sku VARCHAR(32) NOT NULL UNIQUE,
+				title TEXT NOT NULL,
+				price_cents INTEGER NOT NULL
+			)
+		`,
+	}
+
+	files, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generation failed: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(filepath.Dir(files[0]))) })
+
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(filepath.Dir(f))+"/"+filepath.Base(f))
+	}
+	if slices.Contains(names, "model/users.gen.go") {
+		t.Errorf("Expected built-in tables to be skipped, got %v", names)
+	}
+	i := slices.Index(names, "model/products.gen.go")
+	if i < 0 {
+		t.Fatalf("Expected a products model, got %v", names)
+	}
+
+	body, err := os.ReadFile(files[i])
+	if err != nil {
+		t.Fatalf("Failed to read model: %v", err)
+	}
+	for _, column := range []string{"id", "sku", "title", "price_cents"} {
+		if !strings.Contains(string(body), "column:"+column+";") {
+			t.Errorf("Expected model to map column %s:\n%s", column, body)
+		}
+	}
+	if strings.Count(string(body), "column:") != 4 {
+		t.Errorf("Expected exactly 4 columns in model:\n%s", body)
+	}
+}
+
+func TestMySQLBooleanColumns(t *testing.T) {
+	// No MySQL server is part of db-setup, so this runs only when one is provided
+	dsn := os.Getenv("MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_DSN not set, e.g. root:password@tcp(localhost:3306)/mysql")
+	}
+	gen := &CodeGenerator{
+		ConnString: dsn,
+		TempDB:     "gopher_patterns_gen_mysql",
+		OutPath:    filepath.Join(t.TempDir(), "query"),
+		DryRun:     true,
+		Dialect:    DialectMySQL,
+	}
+
+	files, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generation failed: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(filepath.Dir(files[0]))) })
+
+	i := slices.IndexFunc(files, func(f string) bool { return strings.HasSuffix(f, filepath.Join("model", "users.gen.go")) })
+	if i < 0 {
+		t.Fatalf("Expected a users model, got %v", files)
+	}
+	body, err := os.ReadFile(files[i])
+	if err != nil {
+		t.Fatalf("Failed to read model: %v", err)
+	}
+	if !regexp.MustCompile(`Active\s+bool\s`).Match(body) {
+		t.Errorf("Expected tinyint(1) column active to be a bool field:\n%s", body)
+	}
+}
@@ -0,0 +1,76 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// WithCommittedData tests behavior that depends on other connections seeing committed rows, which the
+// default wrapping transaction hides. setup runs in its own transaction on the pool behind db and is
+// committed; assert then runs on a separate connection (neither sees the test's uncommitted writes).
+// Rows inserted or updated by setup are deleted on cleanup; the test's transaction must not lock them.
+// Schema changes made in setup are committed too and stay until the test database is dropped
+func WithCommittedData(t *testing.T, db *gorm.DB, setup func(committed *gorm.DB), assert func(other *gorm.DB)) {
+	t.Helper()
+
+	pool := SQLDB(t, db)
+	committed, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{Logger: db.Logger})
+	require.NoError(t, err, "failed to open committed connection")
+
+	// Rows written by setup carry its transaction id in xmin, which is how cleanup finds them
+	var xid int64
+	err = committed.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT txid_current() % 4294967296").Row().Scan(&xid); err != nil {
+			return err
+		}
+		setup(tx)
+		return nil
+	})
+	require.NoError(t, err, "failed to commit setup data")
+	t.Cleanup(func() {
+		if err := deleteRowsWrittenBy(committed, xid); err != nil {
+			t.Errorf("failed to clean up committed data: %v", err)
+		}
+	})
+
+	conn, err := pool.Conn(context.Background())
+	require.NoError(t, err, "failed to open second connection")
+	defer conn.Close()
+
+	other, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: db.Logger})
+	require.NoError(t, err, "failed to open second connection")
+	assert(other)
+}
+
+// deleteRowsWrittenBy deletes the rows of every table in the current schema last written by transaction xid
+func deleteRowsWrittenBy(db *gorm.DB, xid int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// Fail instead of hanging when the test's transaction still holds a lock on a row
+		if err := tx.Exec("SET LOCAL lock_timeout = '5s'").Error; err != nil {
+			return err
+		}
+
+		var tables []string
+		if err := tx.Raw("SELECT tablename FROM pg_tables WHERE schemaname = current_schema()").Scan(&tables).Error; err != nil {
+			return fmt.Errorf("failed to list tables: %w", err)
+		}
+		for _, table := range tables {
+			if versionTables[table] {
+				continue
+			}
+			quoted, err := quoteIdent(table)
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM "+quoted+" WHERE xmin::text = ?", fmt.Sprint(xid)).Error; err != nil {
+				return fmt.Errorf("failed to delete from %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
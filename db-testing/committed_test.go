@@ -0,0 +1,44 @@
+package dbtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWithCommittedData(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithHook(func(db *gorm.DB) error {
+		return db.AutoMigrate(&User{})
+	}))
+
+	// Uncommitted: only visible inside the wrapping transaction
+	require.NoError(t, db.Create(&User{Name: "Uncommitted"}).Error)
+
+	var committedID uint
+	t.Run("Other connection sees committed rows", func(t *testing.T) {
+		WithCommittedData(t, db,
+			func(committed *gorm.DB) {
+				user := User{Name: "Committed"}
+				require.NoError(t, committed.Create(&user).Error)
+				committedID = user.ID
+			},
+			func(other *gorm.DB) {
+				var names []string
+				require.NoError(t, other.Model(&User{}).Order("name").Pluck("name", &names).Error)
+				assert.Equal(t, []string{"Committed"}, names, "the wrapping transaction's row must stay hidden")
+			},
+		)
+
+		// The wrapping transaction sees both (read committed)
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	// The subtest's cleanup deleted the committed row
+	var exists bool
+	require.NoError(t, SQLDB(t, db).QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", committedID).Scan(&exists))
+	assert.False(t, exists)
+}
@@ -0,0 +1,55 @@
+//go:build embeddedpg
+
+package dbtesting
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/require"
+)
+
+// StartEmbeddedPostgres starts a throwaway Postgres server for tests that can't rely on an external one
+// Pass the returned config to CreateTestDB with DBWithConfig; the server and its data directory are
+// removed when t finishes, so start it once in a parent test and run the suite as subtests.
+// Binaries are downloaded on first use and cached in ~/.embedded-postgres-go.
+// Only built with -tags embeddedpg; the default remains the external db-setup Postgres
+func StartEmbeddedPostgres(t testing.TB) Config {
+	t.Helper()
+
+	config := Config{
+		Host:     "localhost",
+		Port:     freePort(t),
+		User:     "postgres",
+		Password: "password",
+		Database: "postgres",
+	}
+
+	server := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(uint32(config.Port)).
+		Username(config.User).
+		Password(config.Password).
+		Database(config.Database).
+		RuntimePath(t.TempDir()).
+		Logger(io.Discard))
+	require.NoError(t, server.Start(), "failed to start embedded Postgres")
+
+	t.Cleanup(func() {
+		if err := server.Stop(); err != nil {
+			t.Errorf("failed to stop embedded Postgres: %v", err)
+		}
+	})
+	return config
+}
+
+// freePort asks the OS for an unused TCP port
+func freePort(t testing.TB) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err, "failed to find a free port")
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
@@ -0,0 +1,23 @@
+//go:build embeddedpg
+
+package dbtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartEmbeddedPostgres(t *testing.T) {
+	config := StartEmbeddedPostgres(t)
+
+	t.Run("Creates a test database and inserts a row", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithConfig(config))
+		require.NoError(t, db.AutoMigrate(&User{}))
+
+		user := User{Name: "Alice"}
+		require.NoError(t, db.Create(&user).Error)
+		assert.NotZero(t, user.ID)
+	})
+}
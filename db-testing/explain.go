@@ -0,0 +1,30 @@
+package dbtesting
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// AssertUsesIndex runs EXPLAIN on query and fails the test if the plan doesn't reference indexName
+// Postgres-specific. Tiny test tables are usually seq-scanned, so callers may want
+// SET LOCAL enable_seqscan = off (the default wrapping transaction keeps it scoped to the test)
+func AssertUsesIndex(t testing.TB, db *gorm.DB, indexName string, query string, args ...any) {
+	t.Helper()
+
+	plan := ExplainQuery(t, db, query, args...)
+	if !strings.Contains(plan, indexName) {
+		t.Fatalf("Expected query plan to use index %s:\n%s", indexName, plan)
+	}
+}
+
+// ExplainQuery returns the EXPLAIN output of query as text
+func ExplainQuery(t testing.TB, db *gorm.DB, query string, args ...any) string {
+	t.Helper()
+
+	var lines []string
+	require.NoError(t, db.Raw("EXPLAIN "+query, args...).Scan(&lines).Error, "failed to explain query")
+	return strings.Join(lines, "\n")
+}
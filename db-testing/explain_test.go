@@ -0,0 +1,53 @@
+package dbtesting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fatalT records Fatalf instead of failing the test
+type fatalT struct {
+	*testing.T
+	fatal string
+}
+
+func (f *fatalT) Fatalf(format string, args ...any) {
+	f.fatal = fmt.Sprintf(format, args...)
+}
+
+func TestAssertUsesIndex(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithHook(func(db *gorm.DB) error {
+		return db.Exec(`
+			CREATE TABLE accounts (
+				id SERIAL PRIMARY KEY,
+				email VARCHAR(100) NOT NULL,
+				nickname VARCHAR(100) NOT NULL
+			);
+			CREATE INDEX idx_accounts_email ON accounts (email);
+		`).Error
+	}))
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, db.Exec("INSERT INTO accounts (email, nickname) VALUES (?, ?)",
+			fmt.Sprintf("user%d@example.com", i), fmt.Sprintf("user%d", i)).Error)
+	}
+	// Tiny tables are seq-scanned regardless of indexes; scoped to the wrapping transaction
+	require.NoError(t, db.Exec("SET LOCAL enable_seqscan = off").Error)
+
+	t.Run("Indexed column uses index", func(t *testing.T) {
+		AssertUsesIndex(t, db, "idx_accounts_email", "SELECT * FROM accounts WHERE email = ?", "user1@example.com")
+	})
+
+	t.Run("Unindexed column does not use index", func(t *testing.T) {
+		plan := ExplainQuery(t, db, "SELECT * FROM accounts WHERE nickname = ?", "user1")
+		assert.NotContains(t, plan, "idx_accounts_email")
+
+		ft := &fatalT{T: t}
+		AssertUsesIndex(ft, db, "idx_accounts_email", "SELECT * FROM accounts WHERE nickname = ?", "user1")
+		assert.Contains(t, ft.fatal, "Expected query plan to use index idx_accounts_email")
+	})
+}
@@ -0,0 +1,192 @@
+package dbtesting
+
+import (
+	"fmt"
+	"math/rand"
+
+	"gorm.io/gorm"
+)
+
+// Fixture holds rows to insert into a table before the test runs
+type Fixture struct {
+	Table string
+	Rows  []map[string]any
+}
+
+// DBWithFixtures inserts fixture rows after post-init hooks (in committed transaction)
+// Tables are ordered by their foreign keys unless DBFixtureOrder is given
+func DBWithFixtures(fixtures ...Fixture) DBOption {
+	return func(o *dbOptions) {
+		o.Fixtures = append(o.Fixtures, fixtures...)
+	}
+}
+
+// DBFixtureOrder sets an explicit table insert order for fixtures
+// Tables not listed are inserted afterwards in the order they were provided
+func DBFixtureOrder(tables []string) DBOption {
+	return func(o *dbOptions) {
+		o.FixtureOrder = tables
+	}
+}
+
+// DBShuffleSeed shuffles fixture rows within each table before inserting them
+// The same seed always gives the same order, so a failing order can be replayed;
+// use it to catch code that silently relies on insertion order (e.g. List without ORDER BY)
+func DBShuffleSeed(seed int64) DBOption {
+	return func(o *dbOptions) {
+		o.ShuffleSeed = &seed
+	}
+}
+
+// loadFixtures inserts fixtures table by table in dependency order
+// Rows are shuffled per table when shuffleSeed is set
+func loadFixtures(db *gorm.DB, fixtures []Fixture, order []string, shuffleSeed *int64) error {
+	tables, err := fixtureTableOrder(db, fixtures, order)
+	if err != nil {
+		return err
+	}
+
+	rowsByTable := map[string][]map[string]any{}
+	for _, f := range fixtures {
+		rowsByTable[f.Table] = append(rowsByTable[f.Table], f.Rows...)
+	}
+	if shuffleSeed != nil {
+		rng := rand.New(rand.NewSource(*shuffleSeed))
+		for _, table := range tables {
+			rowsByTable[table] = shuffleRows(rowsByTable[table], rng)
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			for _, row := range rowsByTable[table] {
+				if err := tx.Table(table).Create(row).Error; err != nil {
+					return fmt.Errorf("failed to insert fixture into %s: %w", table, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// shuffleRows returns a shuffled copy of rows, leaving the caller's fixtures untouched
+func shuffleRows(rows []map[string]any, rng *rand.Rand) []map[string]any {
+	shuffled := append([]map[string]any(nil), rows...)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// fixtureTableOrder returns the order in which fixture tables are inserted
+func fixtureTableOrder(db *gorm.DB, fixtures []Fixture, order []string) ([]string, error) {
+	var tables []string
+	seen := map[string]bool{}
+	for _, f := range fixtures {
+		if !seen[f.Table] {
+			seen[f.Table] = true
+			tables = append(tables, f.Table)
+		}
+	}
+
+	if len(order) > 0 {
+		return explicitOrder(tables, order), nil
+	}
+	// Foreign keys are read from the Postgres catalog; other engines keep declaration order
+	if db.Dialector.Name() != "postgres" {
+		return tables, nil
+	}
+
+	deps, err := foreignKeyDeps(db)
+	if err != nil {
+		return nil, err
+	}
+	return topoSort(tables, deps)
+}
+
+// explicitOrder puts listed tables first, followed by remaining tables in their original order
+func explicitOrder(tables []string, order []string) []string {
+	present := map[string]bool{}
+	for _, table := range tables {
+		present[table] = true
+	}
+
+	var result []string
+	placed := map[string]bool{}
+	for _, table := range order {
+		if present[table] && !placed[table] {
+			placed[table] = true
+			result = append(result, table)
+		}
+	}
+	for _, table := range tables {
+		if !placed[table] {
+			result = append(result, table)
+		}
+	}
+	return result
+}
+
+// foreignKeyDeps returns, for each table in the current schema, the tables it references
+func foreignKeyDeps(db *gorm.DB) (map[string][]string, error) {
+	var edges []struct {
+		TableName        string
+		ForeignTableName string
+	}
+	err := db.Raw(`
+		SELECT DISTINCT tc.table_name, ccu.table_name AS foreign_table_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.constraint_column_usage ccu
+			ON tc.constraint_name = ccu.constraint_name
+			AND tc.table_schema = ccu.table_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY'
+			AND tc.table_schema = current_schema()
+	`).Scan(&edges).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign keys: %w", err)
+	}
+
+	deps := map[string][]string{}
+	for _, e := range edges {
+		deps[e.TableName] = append(deps[e.TableName], e.ForeignTableName)
+	}
+	return deps, nil
+}
+
+// topoSort orders tables so referenced tables come before tables referencing them
+// Ties keep the original order so results are deterministic
+func topoSort(tables []string, deps map[string][]string) ([]string, error) {
+	inSet := map[string]bool{}
+	for _, table := range tables {
+		inSet[table] = true
+	}
+
+	var result []string
+	done := map[string]bool{}
+	for len(result) < len(tables) {
+		progressed := false
+		for _, table := range tables {
+			if done[table] {
+				continue
+			}
+			ready := true
+			for _, dep := range deps[table] {
+				// Ignore self references and tables without fixtures
+				if dep != table && inSet[dep] && !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[table] = true
+				result = append(result, table)
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			return nil, fmt.Errorf("circular foreign key dependency between fixture tables; use DBFixtureOrder")
+		}
+	}
+	return result, nil
+}
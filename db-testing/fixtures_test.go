@@ -0,0 +1,132 @@
+package dbtesting
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createParentChildHook creates two tables where children references parents
+func createParentChildHook(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE parents (
+			id BIGINT PRIMARY KEY,
+			name VARCHAR(100) NOT NULL
+		);
+		CREATE TABLE children (
+			id BIGINT PRIMARY KEY,
+			parent_id BIGINT NOT NULL REFERENCES parents(id),
+			name VARCHAR(100) NOT NULL
+		);
+	`).Error
+}
+
+func TestFixtures(t *testing.T) {
+	// Fixtures intentionally listed child-first
+	childFirst := []Fixture{
+		{Table: "children", Rows: []map[string]any{
+			{"id": 10, "parent_id": 1, "name": "Child"},
+		}},
+		{Table: "parents", Rows: []map[string]any{
+			{"id": 1, "name": "Parent"},
+		}},
+	}
+
+	t.Run("Topological sort inserts parent first", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest,
+			DBDebugOff,
+			DBWithHook(createParentChildHook),
+			DBWithFixtures(childFirst...),
+		)
+
+		order, err := fixtureTableOrder(db, childFirst, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"parents", "children"}, order)
+
+		var count int64
+		require.NoError(t, db.Table("children").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Explicit order", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest,
+			DBDebugOff,
+			DBWithHook(createParentChildHook),
+			DBWithFixtures(childFirst...),
+			DBFixtureOrder([]string{"parents", "children"}),
+		)
+
+		var count int64
+		require.NoError(t, db.Table("parents").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestShuffleSeed(t *testing.T) {
+	rows := make([]map[string]any, 20)
+	for i := range rows {
+		rows[i] = map[string]any{"id": i + 1, "name": "Parent"}
+	}
+	ids := func(rows []map[string]any) []any {
+		var ids []any
+		for _, row := range rows {
+			ids = append(ids, row["id"])
+		}
+		return ids
+	}
+
+	t.Run("Same seed gives the same order", func(t *testing.T) {
+		first := shuffleRows(rows, rand.New(rand.NewSource(42)))
+		second := shuffleRows(rows, rand.New(rand.NewSource(42)))
+		assert.Equal(t, ids(first), ids(second))
+		assert.NotEqual(t, ids(rows), ids(first))
+		assert.ElementsMatch(t, ids(rows), ids(first))
+
+		// Caller's rows are untouched
+		assert.Equal(t, 1, rows[0]["id"])
+	})
+
+	t.Run("All rows are inserted", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest,
+			DBDebugOff,
+			DBWithHook(createParentChildHook),
+			DBWithFixtures(Fixture{Table: "parents", Rows: rows}),
+			DBShuffleSeed(42),
+		)
+
+		var count int64
+		require.NoError(t, db.Table("parents").Count(&count).Error)
+		assert.Equal(t, int64(len(rows)), count)
+	})
+}
+
+func TestTopoSort(t *testing.T) {
+	t.Run("Dependencies come first", func(t *testing.T) {
+		deps := map[string][]string{
+			"order_items": {"orders", "products"},
+			"orders":      {"users"},
+		}
+		order, err := topoSort([]string{"order_items", "orders", "products", "users"}, deps)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"products", "users", "orders", "order_items"}, order)
+	})
+
+	t.Run("Self reference is ignored", func(t *testing.T) {
+		order, err := topoSort([]string{"categories"}, map[string][]string{"categories": {"categories"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"categories"}, order)
+	})
+
+	t.Run("Cycle returns error", func(t *testing.T) {
+		_, err := topoSort([]string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Explicit order keeps unlisted tables", func(t *testing.T) {
+		order := explicitOrder([]string{"c", "b", "a"}, []string{"a", "missing"})
+		assert.Equal(t, []string{"a", "c", "b"}, order)
+	})
+}
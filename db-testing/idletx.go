@@ -0,0 +1,38 @@
+package dbtesting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// AssertNoIdleInTransaction fails the test for each other session on db's database that is idle in a transaction,
+// i.e. a transaction the code under test began but never committed or rolled back (Postgres only)
+// Call it at the end of the test, or register it with t.Cleanup after CreateTestDB so it runs before the
+// wrapping transaction is rolled back; the session running the check (the wrapping transaction) is excluded
+func AssertNoIdleInTransaction(t testing.TB, db *gorm.DB) {
+	t.Helper()
+
+	var sessions []struct {
+		PID         int
+		State       string
+		IdleSeconds float64
+		Query       string
+	}
+	err := db.Raw(`
+		SELECT pid, state, EXTRACT(EPOCH FROM clock_timestamp() - state_change)::float8 AS idle_seconds, query
+		FROM pg_stat_activity
+		WHERE datname = current_database()
+			AND state IN ('idle in transaction', 'idle in transaction (aborted)')
+			AND pid <> pg_backend_pid()
+		ORDER BY pid
+	`).Scan(&sessions).Error
+	require.NoError(t, err, "failed to read pg_stat_activity")
+
+	for _, s := range sessions {
+		idle := time.Duration(s.IdleSeconds * float64(time.Second)).Round(time.Millisecond)
+		t.Errorf("Session %d has been %s for %s; last query: %s", s.PID, s.State, idle, s.Query)
+	}
+}
@@ -0,0 +1,47 @@
+package dbtesting
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errorfT records Errorf instead of failing the test
+type errorfT struct {
+	*testing.T
+	errors []string
+}
+
+func (e *errorfT) Errorf(format string, args ...any) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoIdleInTransaction(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff)
+
+	t.Run("Passes without other open transactions", func(t *testing.T) {
+		et := &errorfT{T: t}
+		AssertNoIdleInTransaction(et, db)
+		assert.Empty(t, et.errors, "the wrapping transaction itself must not be reported")
+	})
+
+	t.Run("Detects a transaction left open on another connection", func(t *testing.T) {
+		leaked, err := SQLDB(t, db).Begin()
+		require.NoError(t, err)
+		_, err = leaked.Exec("SELECT 1 AS leaked_marker")
+		require.NoError(t, err)
+
+		et := &errorfT{T: t}
+		AssertNoIdleInTransaction(et, db)
+		require.Len(t, et.errors, 1)
+		assert.Contains(t, et.errors[0], "idle in transaction")
+		assert.Contains(t, et.errors[0], "leaked_marker")
+
+		require.NoError(t, leaked.Rollback())
+		et = &errorfT{T: t}
+		AssertNoIdleInTransaction(et, db)
+		assert.Empty(t, et.errors)
+	})
+}
@@ -0,0 +1,78 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// DBWithSchemaIsolation isolates an EnvTest test in its own schema of the base database instead of its own database
+// A connection of the cached base pool is pinned to the test with search_path set to the schema (then public),
+// so AutoMigrate, hooks, fixtures and queries all land in it; the schema is dropped on cleanup. Much cheaper than
+// CREATE DATABASE, but the test runs on a single connection, db.DB() (and SQLDB) is unavailable, and extensions
+// must already be installed in public since they belong to the database
+var DBWithSchemaIsolation DBOption = func(o *dbOptions) {
+	o.SchemaIsolation = true
+}
+
+// isolateSchema creates a test schema in baseDB and returns a handle pinned to one connection using it
+func isolateSchema(ctx context.Context, t testEnv, baseDB *gorm.DB, config Config, opts dbOptions) (*gorm.DB, error) {
+	schema := fmt.Sprintf("test_%d", rand.Intn(10000000))
+	quoted, err := quoteIdent(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := baseDB.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pin a base connection: %w", err)
+	}
+
+	// Registered before the wrapping transaction's rollback, so it runs after it
+	t.Cleanup(func() {
+		// The connection goes back to the shared pool, so restore its search_path first
+		conn.ExecContext(context.Background(), "RESET search_path")
+		conn.Close()
+
+		if opts.KeepOnFailure && t.Failed() {
+			t.Logf("Keeping test schema %s for inspection: %s (then SET search_path TO %s)", schema, config.PsqlCommand(), quoted)
+			return
+		}
+		dropTestSchema(t, baseDB, schema)
+	})
+
+	if _, err := conn.ExecContext(ctx, "CREATE SCHEMA "+quoted); err != nil {
+		return nil, fmt.Errorf("failed to create test schema: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SET search_path TO "+quoted+", public"); err != nil {
+		return nil, fmt.Errorf("failed to set search_path: %w", err)
+	}
+
+	// A separate gorm instance over the pinned connection, so callbacks (e.g. timing) don't touch the cached base handle
+	gormConfig := opts.gormConfig()
+	gormConfig.DisableAutomaticPing = true
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open test schema connection: %w", err)
+	}
+	return db, nil
+}
+
+// dropTestSchema drops a test schema and everything in it, reporting a failure to t
+func dropTestSchema(t testEnv, baseDB *gorm.DB, schema string) {
+	quoted, err := quoteIdent(schema)
+	if err != nil {
+		t.Errorf("Failed to drop test schema: %v", err)
+		return
+	}
+	if err := baseDB.Exec("DROP SCHEMA IF EXISTS " + quoted + " CASCADE").Error; err != nil {
+		t.Errorf("Failed to drop test schema %s: %v", schema, err)
+	}
+}
@@ -0,0 +1,59 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// EnsureRole creates a login role with the given password if it doesn't exist yet
+// Idempotent: an existing role is left untouched (including its password)
+// Useful in CI where the Postgres image may lack the expected role
+func EnsureRole(ctx context.Context, adminDB *gorm.DB, role, password string) error {
+	db := adminDB.WithContext(ctx)
+
+	var exists bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = ?)", role).Row().Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check role %s: %w", role, err)
+	}
+	if exists {
+		return nil
+	}
+
+	quoted, err := quoteIdent(role)
+	if err != nil {
+		return err
+	}
+
+	// CREATE ROLE doesn't accept bind parameters, so the password is escaped as a literal
+	err = db.Exec(fmt.Sprintf(`CREATE ROLE %s WITH LOGIN PASSWORD %s`, quoted, quoteLiteral(password))).Error
+	if err != nil {
+		return fmt.Errorf("failed to create role %s: %w", role, err)
+	}
+	return nil
+}
+
+// quoteLiteral quotes a string as a Postgres literal, doubling embedded single quotes
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// maxIdentLen is Postgres's identifier limit (NAMEDATALEN - 1); longer names are silently truncated
+const maxIdentLen = 63
+
+// quoteIdent double-quotes a Postgres identifier for statements that can't bind it (DDL, table names),
+// doubling embedded double quotes so the name can't break out of the identifier
+// Empty names, names with NUL bytes and names Postgres would truncate are rejected
+func quoteIdent(name string) (string, error) {
+	switch {
+	case name == "":
+		return "", fmt.Errorf("empty identifier")
+	case strings.ContainsRune(name, 0):
+		return "", fmt.Errorf("identifier %q contains a NUL byte", name)
+	case len(name) > maxIdentLen:
+		return "", fmt.Errorf("identifier %q is longer than %d bytes", name, maxIdentLen)
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`, nil
+}
@@ -0,0 +1,66 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureRole(t *testing.T) {
+	// CREATE ROLE is transactional, so the wrapping transaction cleans it up
+	db := CreateTestDB(t, EnvTest, DBDebugOff)
+
+	var canCreateRole bool
+	err := db.Raw("SELECT rolsuper OR rolcreaterole FROM pg_roles WHERE rolname = current_user").Row().Scan(&canCreateRole)
+	require.NoError(t, err)
+	if !canCreateRole {
+		t.Skip("Current user can't create roles")
+	}
+
+	ctx := context.Background()
+	role := fmt.Sprintf("test_role_%d", rand.Intn(10000000))
+
+	require.NoError(t, EnsureRole(ctx, db, role, "it's-secret"))
+
+	var canLogin bool
+	err = db.Raw("SELECT rolcanlogin FROM pg_roles WHERE rolname = ?", role).Row().Scan(&canLogin)
+	require.NoError(t, err)
+	assert.True(t, canLogin)
+
+	// Re-running is a no-op
+	require.NoError(t, EnsureRole(ctx, db, role, "other-password"))
+
+	var count int64
+	require.NoError(t, db.Raw("SELECT count(*) FROM pg_roles WHERE rolname = ?", role).Row().Scan(&count))
+	assert.Equal(t, int64(1), count)
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	assert.Equal(t, "'secret'", quoteLiteral("secret"))
+	assert.Equal(t, "'it''s'", quoteLiteral("it's"))
+	assert.Equal(t, "'''; DROP ROLE x; --'", quoteLiteral("'; DROP ROLE x; --"))
+}
+
+func TestQuoteIdent(t *testing.T) {
+	quoted, err := quoteIdent("users")
+	require.NoError(t, err)
+	assert.Equal(t, `"users"`, quoted)
+
+	quoted, err = quoteIdent("MixedCase")
+	require.NoError(t, err)
+	assert.Equal(t, `"MixedCase"`, quoted, "case is preserved")
+
+	quoted, err = quoteIdent(`foo"; DROP DATABASE x; --`)
+	require.NoError(t, err)
+	assert.Equal(t, `"foo""; DROP DATABASE x; --"`, quoted, "embedded quotes are doubled")
+
+	for _, name := range []string{"", "foo\x00bar", strings.Repeat("a", 64)} {
+		_, err := quoteIdent(name)
+		assert.Error(t, err, "%q should be rejected", name)
+	}
+}
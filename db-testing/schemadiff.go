@@ -0,0 +1,113 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// SchemaDiff is a table or column that differs between two schemas
+// Column is empty when the whole table is missing on one side; A or B is empty on the side it's missing from
+type SchemaDiff struct {
+	Table  string
+	Column string
+	A      string // Column type in a ("table" for table-level diffs)
+	B      string // Column type in b ("table" for table-level diffs)
+}
+
+func (d SchemaDiff) String() string {
+	name := d.Table
+	if d.Column != "" {
+		name += "." + d.Column
+	}
+	switch {
+	case d.A == "":
+		return fmt.Sprintf("%s: only in b (%s)", name, d.B)
+	case d.B == "":
+		return fmt.Sprintf("%s: only in a (%s)", name, d.A)
+	default:
+		return fmt.Sprintf("%s: %s in a, %s in b", name, d.A, d.B)
+	}
+}
+
+// DiffSchema compares tables, columns and column types of the current schemas of a and b (Postgres)
+// Use it to catch drift between GORM models and SQL migrations: migrate one database with the SQL
+// migrations and AutoMigrate the models into the other. Migration version tables are ignored
+func DiffSchema(ctx context.Context, a, b *gorm.DB) ([]SchemaDiff, error) {
+	colsA, err := schemaColumns(ctx, a)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema a: %w", err)
+	}
+	colsB, err := schemaColumns(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema b: %w", err)
+	}
+
+	var diffs []SchemaDiff
+	for table, columnsA := range colsA {
+		columnsB, ok := colsB[table]
+		if !ok {
+			diffs = append(diffs, SchemaDiff{Table: table, A: "table"})
+			continue
+		}
+		for column, typeA := range columnsA {
+			if typeB := columnsB[column]; typeA != typeB {
+				diffs = append(diffs, SchemaDiff{Table: table, Column: column, A: typeA, B: typeB})
+			}
+		}
+		for column, typeB := range columnsB {
+			if _, ok := columnsA[column]; !ok {
+				diffs = append(diffs, SchemaDiff{Table: table, Column: column, B: typeB})
+			}
+		}
+	}
+	for table := range colsB {
+		if _, ok := colsA[table]; !ok {
+			diffs = append(diffs, SchemaDiff{Table: table, B: "table"})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Table != diffs[j].Table {
+			return diffs[i].Table < diffs[j].Table
+		}
+		return diffs[i].Column < diffs[j].Column
+	})
+	return diffs, nil
+}
+
+// schemaColumns returns the column types per table of db's current schema
+func schemaColumns(ctx context.Context, db *gorm.DB) (map[string]map[string]string, error) {
+	var columns []struct {
+		TableName  string
+		ColumnName string
+		ColumnType string
+	}
+	err := db.WithContext(ctx).Raw(`
+		SELECT table_name, column_name,
+			data_type || CASE
+				WHEN character_maximum_length IS NOT NULL THEN '(' || character_maximum_length || ')'
+				WHEN data_type = 'numeric' AND numeric_precision IS NOT NULL THEN '(' || numeric_precision || ',' || numeric_scale || ')'
+				ELSE ''
+			END AS column_type
+		FROM information_schema.columns
+		WHERE table_schema = current_schema()
+	`).Scan(&columns).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tables := map[string]map[string]string{}
+	for _, c := range columns {
+		if versionTables[c.TableName] {
+			continue
+		}
+		if tables[c.TableName] == nil {
+			tables[c.TableName] = map[string]string{}
+		}
+		tables[c.TableName][c.ColumnName] = c.ColumnType
+	}
+	return tables, nil
+}
@@ -0,0 +1,54 @@
+package dbtesting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// DriftedOrder is the Order model with a field the SQL migration doesn't have
+type DriftedOrder struct {
+	ID        uint    `gorm:"primaryKey"`
+	UserID    int64   `gorm:"not null"`
+	Product   string  `gorm:"size:100;not null"`
+	Amount    float64 `gorm:"type:decimal(10,2);not null"`
+	CreatedAt string  `gorm:"type:timestamp"`
+	Note      string  `gorm:"size:255"`
+}
+
+func (DriftedOrder) TableName() string {
+	return "orders"
+}
+
+func TestDiffSchema(t *testing.T) {
+	ctx := context.Background()
+	migrated := CreateTestDB(t, EnvTest, DBDebugOff, DBWithHook(func(db *gorm.DB) error {
+		return newTestMigrator(db).up(ctx)
+	}))
+	modeled := CreateTestDB(t, EnvTest, DBDebugOff, DBWithHook(func(db *gorm.DB) error {
+		return db.AutoMigrate(&DriftedOrder{})
+	}))
+
+	diffs, err := DiffSchema(ctx, migrated, modeled)
+	require.NoError(t, err)
+	assert.Equal(t, []SchemaDiff{
+		{Table: "orders", Column: "note", B: "character varying(255)"},
+	}, diffs)
+	assert.Equal(t, "orders.note: only in b (character varying(255))", diffs[0].String())
+
+	t.Run("Identical schemas", func(t *testing.T) {
+		diffs, err := DiffSchema(ctx, migrated, migrated)
+		require.NoError(t, err)
+		assert.Empty(t, diffs)
+	})
+
+	t.Run("Missing table", func(t *testing.T) {
+		empty := CreateTestDB(t, EnvTest, DBDebugOff)
+		diffs, err := DiffSchema(ctx, migrated, empty)
+		require.NoError(t, err)
+		assert.Equal(t, []SchemaDiff{{Table: "orders", A: "table"}}, diffs)
+	})
+}
@@ -0,0 +1,38 @@
+package dbtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// AssertNextID fails the test if the next id generated for table's id column isn't expected
+// Catches identity-reset bugs, e.g. expect 1 after TRUNCATE ... RESTART IDENTITY
+func AssertNextID(t testing.TB, db *gorm.DB, table string, expected int64) {
+	t.Helper()
+
+	if next := NextID(t, db, table); next != expected {
+		t.Fatalf("Expected next id of %s to be %d, got %d", table, expected, next)
+	}
+}
+
+// NextID returns the value the id column's sequence (serial or identity) will hand out next
+// The sequence is read, not advanced: nextval isn't undone by a rollback, so peeking with it would skip an id
+func NextID(t testing.TB, db *gorm.DB, table string) int64 {
+	t.Helper()
+
+	var sequence *string
+	require.NoError(t, db.Raw("SELECT pg_get_serial_sequence(?, 'id')", table).Row().Scan(&sequence),
+		"failed to look up id sequence of %s", table)
+	require.NotNil(t, sequence, "%s.id is not backed by a sequence", table)
+
+	// pg_get_serial_sequence returns an already quoted name
+	var next int64
+	err := db.Raw(`
+		SELECT CASE WHEN is_called THEN last_value + (SELECT seqincrement FROM pg_sequence WHERE seqrelid = ?::regclass)
+			ELSE last_value END
+		FROM `+*sequence, *sequence).Row().Scan(&next)
+	require.NoError(t, err, "failed to read sequence %s", *sequence)
+	return next
+}
@@ -0,0 +1,28 @@
+package dbtesting
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// txPools maps the *sql.Tx of transaction-wrapped test DBs to the pool they were started from
+var txPools sync.Map
+
+// SQLDB returns the *sql.DB behind a gorm handle, failing the test if there is none
+// For transaction-wrapped handles from CreateTestDB this is the underlying pool, not the transaction:
+// statements run on it are outside the test transaction (not rolled back, and can't see uncommitted rows)
+func SQLDB(t testing.TB, db *gorm.DB) *sql.DB {
+	t.Helper()
+
+	if pool, ok := txPools.Load(db.Statement.ConnPool); ok {
+		return pool.(*sql.DB)
+	}
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err, "failed to get *sql.DB from gorm handle")
+	return sqlDB
+}
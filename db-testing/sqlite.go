@@ -0,0 +1,67 @@
+package dbtesting
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Driver selects the database engine behind CreateTestDB
+type Driver int
+
+const (
+	// DriverPostgres creates Postgres databases per the environment (default)
+	DriverPostgres Driver = iota
+	// DriverSQLite opens a fresh in-memory SQLite database per test, no server needed
+	DriverSQLite
+)
+
+func (d Driver) String() string {
+	switch d {
+	case DriverPostgres:
+		return "postgres"
+	case DriverSQLite:
+		return "sqlite"
+	default:
+		return "unknown"
+	}
+}
+
+// DBWithDriver selects the database engine; with DriverSQLite the environment and connection config are ignored
+// Hooks, fixtures and transaction wrapping work as with Postgres, but fixtures are inserted in declaration
+// order (or DBFixtureOrder) rather than by foreign keys, and DBWithExtensions needs Postgres
+func DBWithDriver(driver Driver) DBOption {
+	return func(o *dbOptions) {
+		o.Driver = driver
+	}
+}
+
+// openSQLiteDB opens a uniquely named in-memory SQLite database, closed on cleanup
+// The shared cache lets every pooled connection see the same database (a plain :memory: is per connection)
+func openSQLiteDB(ctx context.Context, t testEnv, opts dbOptions) (*gorm.DB, error) {
+	dsn := fmt.Sprintf("file:test_db_%d?mode=memory&cache=shared&_fk=1", rand.Intn(10000000))
+	db, err := gorm.Open(sqlite.Open(dsn), opts.gormConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	// The database is dropped when its last connection closes, so hold one until cleanup
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	keepAlive, err := sqlDB.Conn(ctx)
+	if err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+
+	t.Cleanup(func() {
+		keepAlive.Close()
+		sqlDB.Close()
+	})
+	return db, nil
+}
@@ -0,0 +1,132 @@
+package dbtesting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// DBWithTemplate clones each EnvTest database from the template database name (CREATE DATABASE ... TEMPLATE)
+// instead of creating it empty and running extensions, hooks and fixtures every time, which is much faster
+// for large suites. The first caller in the process (re)builds the template with its options; later callers
+// just clone it, so they must pass the same hooks and fixtures. Use a name unique to the test package, since
+// packages run as separate processes in parallel and each rebuilds its template
+func DBWithTemplate(name string) DBOption {
+	return func(o *dbOptions) {
+		o.Template = name
+	}
+}
+
+const (
+	// templateCloneAttempts bounds retries of a clone refused because the template is in use
+	templateCloneAttempts = 5
+	// templateCloneBackoff is the wait between clone attempts, multiplied by the attempt number
+	templateCloneBackoff = 50 * time.Millisecond
+)
+
+// templateBuild guards building one template database; built is only set on success so failures are retried
+type templateBuild struct {
+	mu    sync.Mutex
+	built bool
+}
+
+// templates holds the template databases of this process, keyed by their connection string
+var templates = map[string]*templateBuild{}
+var templatesMutex = &sync.Mutex{}
+
+// ensureTemplate builds the template database config.Database once per process
+func ensureTemplate(ctx context.Context, t testEnv, baseDB *gorm.DB, config Config, opts dbOptions) error {
+	templatesMutex.Lock()
+	build, exists := templates[config.ConnString()]
+	if !exists {
+		build = &templateBuild{}
+		templates[config.ConnString()] = build
+	}
+	templatesMutex.Unlock()
+
+	build.mu.Lock()
+	defer build.mu.Unlock()
+	if build.built {
+		return nil
+	}
+	if err := buildTemplate(ctx, t, baseDB, config, opts); err != nil {
+		return fmt.Errorf("failed to build template database %s: %w", config.Database, err)
+	}
+	build.built = true
+	return nil
+}
+
+// buildTemplate recreates the template database and runs the options' extensions, hooks and fixtures in it
+// A template left by an earlier run is dropped, since its schema may predate the current hooks
+func buildTemplate(ctx context.Context, t testEnv, baseDB *gorm.DB, config Config, opts dbOptions) error {
+	quoted, err := quoteIdent(config.Database)
+	if err != nil {
+		return err
+	}
+	admin := baseDB.WithContext(ctx)
+	if err := admin.Exec("DROP DATABASE IF EXISTS " + quoted + " WITH (FORCE)").Error; err != nil {
+		return fmt.Errorf("failed to drop previous template: %w", err)
+	}
+	if err := admin.Exec("CREATE DATABASE " + quoted).Error; err != nil {
+		return err
+	}
+	t.Logf("Building template database %s", config.Database)
+
+	db, err := openDB(ctx, postgres.Open(config.ConnString()), opts.gormConfig())
+	if err != nil {
+		return err
+	}
+	// Close every connection afterwards: Postgres refuses to clone a database in use
+	defer func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}()
+
+	return initDB(t, db.WithContext(ctx), opts)
+}
+
+// cloneTemplate creates database name as a copy of template
+// Postgres refuses to clone a template with open connections (e.g. a pool still closing or a psql session),
+// so those are terminated and the clone retried
+func cloneTemplate(ctx context.Context, baseDB *gorm.DB, name, template string) error {
+	quotedName, err := quoteIdent(name)
+	if err != nil {
+		return err
+	}
+	quotedTemplate, err := quoteIdent(template)
+	if err != nil {
+		return err
+	}
+
+	admin := baseDB.WithContext(ctx)
+	for attempt := 1; ; attempt++ {
+		err := admin.Exec("CREATE DATABASE " + quotedName + " TEMPLATE " + quotedTemplate).Error
+		if err == nil || !isObjectInUse(err) || attempt == templateCloneAttempts {
+			return err
+		}
+
+		err = admin.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = ? AND pid <> pg_backend_pid()", template).Error
+		if err != nil {
+			return fmt.Errorf("failed to disconnect template sessions: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(templateCloneBackoff * time.Duration(attempt)):
+		}
+	}
+}
+
+// isObjectInUse reports whether err is Postgres object_in_use (the template has other sessions)
+func isObjectInUse(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "55006"
+}
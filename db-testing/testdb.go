@@ -1,9 +1,16 @@
 package dbtesting
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"math/rand"
+	"net"
+	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -40,12 +47,35 @@ type Config struct {
 	User     string
 	Password string
 	Database string
+	SSLMode  string // Defaults to disable
 }
 
 // ConnString returns PostgreSQL connection string
 func (c Config) ConnString() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		c.Host, c.Port, c.User, c.Password, c.Database)
+	sslMode := c.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		c.Host, c.Port, c.User, c.Password, c.Database, sslMode)
+}
+
+// PsqlCommand returns a ready-to-paste psql command connecting to this database
+// Values are shell-quoted, so passwords with spaces or $ survive the paste
+func (c Config) PsqlCommand() string {
+	return fmt.Sprintf("PGPASSWORD=%s psql -h %s -p %d -U %s -d %s",
+		shellQuote(c.Password), shellQuote(c.Host), c.Port, shellQuote(c.User), shellQuote(c.Database))
+}
+
+// shellSafe matches values that need no quoting in a POSIX shell
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote single-quotes s for a POSIX shell unless it is made of safe characters only
+func shellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 // GetConfig returns database config for environment
@@ -82,7 +112,23 @@ func GetConfig(env Env) Config {
 type dbOptions struct {
 	DebugOff            bool                   // Turn off SQL query logging
 	NoWrapInTransaction bool                   // Skip transaction wrapping
+	DeferredInTx        bool                   // Defer constraint checks in the wrapping transaction
+	KeepOnFailure       bool                   // Keep the EnvTest database when the test fails
+	EnsureDatabase      bool                   // Create the configured database if it doesn't exist
+	CollectHookErrors   bool                   // Run every post-init hook and report all failures together
 	PostInitHooks       []func(*gorm.DB) error // Hooks to run after DB initialization (in committed transaction)
+	Extensions          []string               // Postgres extensions to create before hooks (committed)
+	Fixtures            []Fixture              // Rows to insert after hooks (committed)
+	FixtureOrder        []string               // Explicit fixture table order (topological sort when empty)
+	ShuffleSeed         *int64                 // Shuffle fixture rows within each table using this seed
+	ParentTx            *gorm.DB               // Nest in a savepoint of this transaction instead of creating a database
+	Config              *Config                // Connection config overriding GetConfig(env)
+	TimingReportTopN    int                    // Log this many slowest queries on cleanup (0 disables timing)
+	Driver              Driver                 // Database engine (Postgres by default)
+	Context             context.Context        // Bounds connecting and setup queries (background when nil)
+	Template            string                 // Clone EnvTest databases from this template, built once per process
+	SchemaIsolation     bool                   // Isolate EnvTest in a schema of the base database instead of a database
+	GormConfig          []func(*gorm.Config)   // Adjust the test database's gorm config before opening it
 }
 
 // DBOption configures database behavior
@@ -98,6 +144,58 @@ var DBNoWrapInTransaction DBOption = func(o *dbOptions) {
 	o.NoWrapInTransaction = true
 }
 
+// DBDeferredInTx defers constraint checks in the wrapping transaction (SET CONSTRAINTS ALL DEFERRED)
+// FK violations then surface only at commit, so rows can be inserted in any order
+// Only constraints declared DEFERRABLE are affected; has no effect with DBNoWrapInTransaction
+var DBDeferredInTx DBOption = func(o *dbOptions) {
+	o.DeferredInTx = true
+}
+
+// DBKeepOnFailure keeps the isolated EnvTest database when the test fails
+// The cleanup logs a psql command to inspect it; drop it manually afterwards
+// Combine with DBNoWrapInTransaction so the test's writes are actually committed
+var DBKeepOnFailure DBOption = func(o *dbOptions) {
+	o.KeepOnFailure = true
+}
+
+// DBEnsureDatabase creates the configured database when it doesn't exist: the base database for EnvTest,
+// the shared database for EnvDev. It connects to a maintenance database (postgres, or template1) to do so,
+// which needs the CREATEDB privilege; handy for custom servers and fresh dev containers
+var DBEnsureDatabase DBOption = func(o *dbOptions) {
+	o.EnsureDatabase = true
+}
+
+// DBCollectHookErrors runs every post-init hook even after one fails, then fails the test once
+// with all hook errors joined, so independent setup problems are reported in a single run
+var DBCollectHookErrors DBOption = func(o *dbOptions) {
+	o.CollectHookErrors = true
+}
+
+// DBWithGormConfig adjusts the gorm config of the test database before it is opened, e.g. to match production's
+// NamingStrategy, PrepareStmt or DisableForeignKeyConstraintWhenMigrating. configure runs after the logger is
+// chosen (see DBDebugOff), so setting Logger overrides it
+func DBWithGormConfig(configure func(*gorm.Config)) DBOption {
+	return func(o *dbOptions) {
+		o.GormConfig = append(o.GormConfig, configure)
+	}
+}
+
+// gormConfig returns the gorm config for opening a test database
+func (o dbOptions) gormConfig() *gorm.Config {
+	logLevel := logger.Info
+	if o.DebugOff {
+		logLevel = logger.Error
+	}
+
+	config := &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel),
+	}
+	for _, configure := range o.GormConfig {
+		configure(config)
+	}
+	return config
+}
+
 // DBWithHook adds a post-initialization hook that runs in a committed transaction
 func DBWithHook(hook func(*gorm.DB) error) DBOption {
 	return func(o *dbOptions) {
@@ -105,11 +203,77 @@ func DBWithHook(hook func(*gorm.DB) error) DBOption {
 	}
 }
 
+// DBWithExtensions creates Postgres extensions (e.g. pgcrypto, uuid-ossp) before hooks run
+// The test is skipped if an extension is not available on the server
+func DBWithExtensions(names ...string) DBOption {
+	return func(o *dbOptions) {
+		o.Extensions = append(o.Extensions, names...)
+	}
+}
+
+// DBWithConfig connects using config instead of the environment's default, e.g. a server from StartEmbeddedPostgres
+// With EnvTest, config.Database is the base database the isolated test databases are created from
+func DBWithConfig(config Config) DBOption {
+	return func(o *dbOptions) {
+		o.Config = &config
+	}
+}
+
+// DBWithContext bounds database setup with ctx: connecting, the version probe, creating the database,
+// extensions, hooks and fixtures stop when ctx is cancelled or its deadline passes, so a hung server fails
+// the test instead of blocking it. A test database created before cancellation is still dropped on cleanup;
+// the returned handle and its wrapping transaction don't carry ctx
+func DBWithContext(ctx context.Context) DBOption {
+	return func(o *dbOptions) {
+		o.Context = ctx
+	}
+}
+
+// DBWithParentTx nests the test in a savepoint of an existing transaction instead of creating a database
+// Everything the test does (including hooks and fixtures) is rolled back to the savepoint on cleanup,
+// which is much faster for suites sharing one database. The env and wrapping options are ignored;
+// tests sharing a parent transaction must not run in parallel
+func DBWithParentTx(tx *gorm.DB) DBOption {
+	return func(o *dbOptions) {
+		o.ParentTx = tx
+	}
+}
+
 // Connection cache for performance
 var connections = map[string]*gorm.DB{}
 var connectionsMutex = &sync.Mutex{}
 
-func getCachedDB(connString string) (*gorm.DB, error) {
+// connectionCacheDisabled makes getBaseDB open a fresh connection for every CreateTestDB
+var connectionCacheDisabled atomic.Bool
+
+// DisableConnectionCacheForRace stops sharing base connections between CreateTestDB calls when built with -race
+// Every call then opens (and closes on cleanup) its own connection pool, so races hidden by a shared
+// handle surface. Call it from TestMain; it's a no-op without the race detector
+func DisableConnectionCacheForRace() {
+	if raceEnabled {
+		connectionCacheDisabled.Store(true)
+	}
+}
+
+// getBaseDB returns the cached connection for connString, or a fresh one closed on cleanup when caching is disabled
+func getBaseDB(ctx context.Context, t testEnv, connString string) (*gorm.DB, error) {
+	if !connectionCacheDisabled.Load() {
+		return getCachedDB(ctx, connString)
+	}
+
+	db, err := openBaseDB(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db, nil
+}
+
+func getCachedDB(ctx context.Context, connString string) (*gorm.DB, error) {
 	connectionsMutex.Lock()
 	defer connectionsMutex.Unlock()
 
@@ -117,14 +281,38 @@ func getCachedDB(connString string) (*gorm.DB, error) {
 		return db, nil
 	}
 
-	db, err := gorm.Open(postgres.Open(connString), &gorm.Config{
+	db, err := openBaseDB(ctx, connString)
+	if err != nil {
+		return nil, err
+	}
+
+	connections[connString] = db
+	return db, nil
+}
+
+// openBaseDB opens a connection to a base database with query logging off
+func openBaseDB(ctx context.Context, connString string) (*gorm.DB, error) {
+	return openDB(ctx, postgres.Open(connString), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Error),
 	})
+}
+
+// openDB opens dialector and checks the connection with ctx instead of gorm's unbounded ping
+func openDB(ctx context.Context, dialector gorm.Dialector, config *gorm.Config) (*gorm.DB, error) {
+	config.DisableAutomaticPing = true
+	db, err := gorm.Open(dialector, config)
 	if err != nil {
 		return nil, err
 	}
 
-	connections[connString] = db
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -133,109 +321,405 @@ func DefaultConfig() Config {
 	return GetConfig(EnvTest)
 }
 
+// ErrDatabaseUnavailable is returned by NewTestDB when the database can't be used (dev database down, missing extension)
+// CreateTestDB skips the test instead of failing it
+var ErrDatabaseUnavailable = errors.New("database not available")
+
+// testEnv is the part of *testing.T used while setting up a test database, so NewTestDB can run without one
+type testEnv interface {
+	Logf(format string, args ...any)
+	Errorf(format string, args ...any)
+	Cleanup(func())
+	Failed() bool
+}
+
+// standaloneEnv is the testEnv of NewTestDB: logs go to the standard logger and cleanups run on close
+type standaloneEnv struct {
+	mu       sync.Mutex
+	cleanups []func()
+}
+
+func (e *standaloneEnv) Logf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+// Errorf only logs: errors that matter are returned by NewTestDB
+func (e *standaloneEnv) Errorf(format string, args ...any) {
+	log.Printf(format, args...)
+}
+
+func (e *standaloneEnv) Cleanup(fn func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cleanups = append(e.cleanups, fn)
+}
+
+// Failed is always false: there is no test to fail
+func (e *standaloneEnv) Failed() bool {
+	return false
+}
+
+// close runs the cleanups in reverse order like testing.T; later calls are no-ops
+func (e *standaloneEnv) close() {
+	e.mu.Lock()
+	cleanups := e.cleanups
+	e.cleanups = nil
+	e.mu.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
 // CreateTestDB creates test database with environment and options support
+// Setup errors fail the test; an unavailable database (ErrDatabaseUnavailable) skips it
 func CreateTestDB(t *testing.T, env Env, options ...DBOption) *gorm.DB {
+	t.Helper()
+
+	db, err := newTestDB(t, env, options...)
+	if errors.Is(err, ErrDatabaseUnavailable) {
+		t.Skip(err)
+		return nil
+	}
+	require.NoError(t, err)
+	return db
+}
+
+// NewTestDB creates a test database like CreateTestDB without a *testing.T, e.g. from TestMain or a benchmark harness
+// The returned cleanup rolls back, drops the database and closes connections; it is safe to call more than once
+// Logs go to the standard logger and DBKeepOnFailure has no effect. On error everything created so far is released
+func NewTestDB(env Env, options ...DBOption) (*gorm.DB, func(), error) {
+	e := &standaloneEnv{}
+	db, err := newTestDB(e, env, options...)
+	if err != nil {
+		e.close()
+		return nil, nil, err
+	}
+	return db, e.close, nil
+}
+
+// newTestDB creates the test database, registering its cleanups on t
+func newTestDB(t testEnv, env Env, options ...DBOption) (*gorm.DB, error) {
 	var opts dbOptions
 	for _, option := range options {
 		option(&opts)
 	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if opts.ParentTx != nil {
+		parent, err := nestInParentTx(t, opts.ParentTx)
+		if err != nil {
+			return nil, err
+		}
+		return setupTestDB(ctx, t, parent, opts)
+	}
+	if opts.Driver == DriverSQLite {
+		db, err := openSQLiteDB(ctx, t, opts)
+		if err != nil {
+			return nil, err
+		}
+		return setupTestDB(ctx, t, db, opts)
+	}
 
 	config := GetConfig(env)
+	if opts.Config != nil {
+		config = *opts.Config
+	}
 	var db *gorm.DB
 
 	switch env {
 	case EnvTest:
-		// Connect to base database using cache
-		baseDB, err := getCachedDB(config.ConnString())
-		require.NoError(t, err, "failed to connect to base database")
+		if opts.EnsureDatabase {
+			if err := ensureDatabase(ctx, config); err != nil {
+				return nil, fmt.Errorf("failed to ensure base database: %w", err)
+			}
+		}
+
+		// Connect to base database using cache (the connection outlives the test database drop on cleanup)
+		baseDB, err := getBaseDB(ctx, t, config.ConnString())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to base database: %w", err)
+		}
 
 		// Test database connectivity
 		var version string
-		err = baseDB.Raw("SELECT version()").Row().Scan(&version)
-		require.NoError(t, err)
-		require.NotEmpty(t, version)
+		if err := baseDB.WithContext(ctx).Raw("SELECT version()").Row().Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to query base database version: %w", err)
+		}
 		t.Logf("Database version: %s", version)
 
-		// Create unique test database
-		testDBName := fmt.Sprintf("test_db_%d", rand.Intn(10000000))
-		err = baseDB.Exec(fmt.Sprintf("CREATE DATABASE %s", testDBName)).Error
-		require.NoError(t, err)
+		if opts.SchemaIsolation {
+			if opts.Template != "" {
+				return nil, errors.New("DBWithSchemaIsolation can't be combined with DBWithTemplate")
+			}
+			schemaDB, err := isolateSchema(ctx, t, baseDB, config, opts)
+			if err != nil {
+				return nil, err
+			}
+			return setupTestDB(ctx, t, schemaDB, opts)
+		}
 
-		// Connect to test database
-		config.Database = testDBName
-		logLevel := logger.Info
-		if opts.DebugOff {
-			logLevel = logger.Error
+		if opts.Template != "" {
+			templateConfig := config
+			templateConfig.Database = opts.Template
+			if err := ensureTemplate(ctx, t, baseDB, templateConfig, opts); err != nil {
+				return nil, err
+			}
 		}
 
-		testDB, err := gorm.Open(postgres.Open(config.ConnString()), &gorm.Config{
-			Logger: logger.Default.LogMode(logLevel),
-		})
-		require.NoError(t, err)
+		// Create unique test database
+		testDBName := fmt.Sprintf("test_db_%d", rand.Intn(10000000))
+		quotedName, err := quoteIdent(testDBName)
+		if err != nil {
+			return nil, err
+		}
+		config.Database = testDBName
 
-		// Cleanup on test completion
+		// Register cleanup first: a cancelled CREATE DATABASE may still have created the database
+		var testDB *gorm.DB
 		t.Cleanup(func() {
-			sqlDB, _ := testDB.DB()
-			if sqlDB != nil {
-				sqlDB.Close()
+			if testDB != nil {
+				if sqlDB, err := testDB.DB(); err == nil {
+					sqlDB.Close()
+				}
 			}
-			baseDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", testDBName))
+			dropTestDB(t, baseDB, config, opts.KeepOnFailure)
 		})
 
+		if opts.Template != "" {
+			if err := cloneTemplate(ctx, baseDB, testDBName, opts.Template); err != nil {
+				return nil, fmt.Errorf("failed to clone template database %s: %w", opts.Template, err)
+			}
+			// Extensions, hooks and fixtures are already in the template
+			opts.Extensions, opts.PostInitHooks, opts.Fixtures = nil, nil, nil
+		} else if err := baseDB.WithContext(ctx).Exec("CREATE DATABASE " + quotedName).Error; err != nil {
+			return nil, fmt.Errorf("failed to create test database: %w", err)
+		}
+
+		// Connect to test database
+		testDB, err = openDB(ctx, postgres.Open(config.ConnString()), opts.gormConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to test database: %w", err)
+		}
+
 		db = testDB
 
 	case EnvDev:
-		// Connect to shared development database
-		logLevel := logger.Info
-		if opts.DebugOff {
-			logLevel = logger.Error
+		if opts.EnsureDatabase {
+			if err := ensureDatabase(ctx, config); err != nil {
+				if isUnreachable(err) {
+					return nil, fmt.Errorf("dev %w: %w", ErrDatabaseUnavailable, err)
+				}
+				return nil, fmt.Errorf("failed to ensure dev database: %w", err)
+			}
 		}
 
-		devDB, err := gorm.Open(postgres.Open(config.ConnString()), &gorm.Config{
-			Logger: logger.Default.LogMode(logLevel),
-		})
-
+		// Connect to shared development database
+		devDB, err := openDB(ctx, postgres.Open(config.ConnString()), opts.gormConfig())
 		if err != nil {
-			t.Skipf("Dev database not available: %v", err)
-			return nil
+			return nil, fmt.Errorf("dev %w: %w", ErrDatabaseUnavailable, err)
 		}
 
 		// Test connectivity
 		var version string
-		err = devDB.Raw("SELECT version()").Row().Scan(&version)
+		err = devDB.WithContext(ctx).Raw("SELECT version()").Row().Scan(&version)
 		if err != nil {
-			t.Skipf("Dev database not accessible: %v", err)
-			return nil
+			return nil, fmt.Errorf("dev %w: %w", ErrDatabaseUnavailable, err)
 		}
 		t.Logf("Dev database version: %s", version)
 
 		db = devDB
 
 	default:
-		t.Fatalf("Unknown environment: %v", env)
+		return nil, fmt.Errorf("unknown environment: %v", env)
+	}
+
+	return setupTestDB(ctx, t, db, opts)
+}
+
+// maintenanceDatabases are tried in order to create missing databases; template1 always exists
+var maintenanceDatabases = []string{"postgres", "template1"}
+
+// ensureDatabase creates config.Database through a maintenance database connection if it doesn't exist
+func ensureDatabase(ctx context.Context, config Config) error {
+	quoted, err := quoteIdent(config.Database)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, maintenance := range maintenanceDatabases {
+		if maintenance == config.Database {
+			continue
+		}
+		admin := config
+		admin.Database = maintenance
+		db, err := openBaseDB(ctx, admin.ConnString())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		defer func() {
+			if sqlDB, err := db.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}()
+
+		var exists bool
+		if err := db.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = ?)", config.Database).Row().Scan(&exists); err != nil {
+			return fmt.Errorf("failed to check database %s: %w", config.Database, err)
+		}
+		if exists {
+			return nil
+		}
+		if err := db.WithContext(ctx).Exec("CREATE DATABASE " + quoted).Error; err != nil {
+			return fmt.Errorf("failed to create database %s: %w", config.Database, err)
+		}
 		return nil
 	}
+	return fmt.Errorf("failed to connect to a maintenance database: %w", errors.Join(errs...))
+}
 
-	// Run post-initialization hooks in committed transactions
-	for i, hook := range opts.PostInitHooks {
+// isUnreachable reports whether err comes from failing to reach the server (connection refused, DNS, timeout)
+// rather than from the server rejecting a query
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// runPostInitHooks runs hooks in order, stopping at the first error unless collect is set,
+// in which case every hook runs and their errors are joined
+func runPostInitHooks(t testEnv, db *gorm.DB, hooks []func(*gorm.DB) error, collect bool) error {
+	var errs []error
+	for i, hook := range hooks {
 		t.Logf("Running post-init hook %d", i+1)
-		err := hook(db)
-		require.NoError(t, err, "Post-init hook %d failed", i+1)
+		if err := hook(db); err != nil {
+			err = fmt.Errorf("post-init hook %d: %w", i+1, err)
+			if !collect {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// nestInParentTx creates a savepoint on the parent transaction and rolls back to it on cleanup
+func nestInParentTx(t testEnv, parent *gorm.DB) (*gorm.DB, error) {
+	savepoint := fmt.Sprintf("test_sp_%d", rand.Intn(10000000))
+	if err := parent.SavePoint(savepoint).Error; err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	t.Cleanup(func() {
+		parent.RollbackTo(savepoint)
+	})
+
+	return parent, nil
+}
+
+// setupTestDB initializes db (see initDB) and wraps it in a transaction per options
+// Setup queries run with ctx; the wrapping transaction doesn't, since it lives as long as the test
+func setupTestDB(ctx context.Context, t testEnv, db *gorm.DB, opts dbOptions) (*gorm.DB, error) {
+	if err := initDB(t, db.WithContext(ctx), opts); err != nil {
+		return nil, err
 	}
 
-	// Wrap in transaction unless disabled
-	if !opts.NoWrapInTransaction {
+	// Time only the test's own queries, so start after hooks and fixtures
+	if opts.TimingReportTopN > 0 {
+		timed, err := withTimingReport(t, db, opts.TimingReportTopN)
+		if err != nil {
+			return nil, err
+		}
+		db = timed
+	}
+
+	// Wrap in transaction unless disabled (a parent transaction savepoint already isolates the test)
+	if !opts.NoWrapInTransaction && opts.ParentTx == nil {
 		tx := db.Begin()
-		require.NoError(t, tx.Error)
+		if tx.Error != nil {
+			return nil, fmt.Errorf("failed to begin test transaction: %w", tx.Error)
+		}
+
+		// Remember the pool behind the transaction for SQLDB
+		if sqlDB, err := db.DB(); err == nil {
+			txPools.Store(tx.Statement.ConnPool, sqlDB)
+		}
 
 		t.Cleanup(func() {
 			tx.Rollback()
+			txPools.Delete(tx.Statement.ConnPool)
 		})
 
+		if opts.DeferredInTx {
+			deferConstraints := "SET CONSTRAINTS ALL DEFERRED"
+			if opts.Driver == DriverSQLite {
+				deferConstraints = "PRAGMA defer_foreign_keys = ON"
+			}
+			if err := tx.Exec(deferConstraints).Error; err != nil {
+				return nil, fmt.Errorf("failed to defer constraints: %w", err)
+			}
+		}
+
 		db = tx
 	}
 
-	return db
+	return db, nil
+}
+
+// initDB creates extensions, runs hooks and loads fixtures, all committed
+func initDB(t testEnv, db *gorm.DB, opts dbOptions) error {
+	// Create extensions before hooks so migrations can rely on them
+	for _, name := range opts.Extensions {
+		var available bool
+		err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = ?)", name).Row().Scan(&available)
+		if err != nil {
+			return fmt.Errorf("failed to check extension %s: %w", name, err)
+		}
+		if !available {
+			return fmt.Errorf("%w: Postgres extension %s is not available on this server", ErrDatabaseUnavailable, name)
+		}
+
+		quoted, err := quoteIdent(name)
+		if err != nil {
+			return fmt.Errorf("invalid extension name: %w", err)
+		}
+		if err := db.Exec("CREATE EXTENSION IF NOT EXISTS " + quoted).Error; err != nil {
+			return fmt.Errorf("failed to create extension %s: %w", name, err)
+		}
+	}
+
+	// Run post-initialization hooks in committed transactions
+	if err := runPostInitHooks(t, db, opts.PostInitHooks, opts.CollectHookErrors); err != nil {
+		return fmt.Errorf("post-init hooks failed: %w", err)
+	}
+
+	// Load fixtures after hooks so migrated schema is available
+	if len(opts.Fixtures) > 0 {
+		if err := loadFixtures(db, opts.Fixtures, opts.FixtureOrder, opts.ShuffleSeed); err != nil {
+			return fmt.Errorf("loading fixtures failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// dropTestDB drops the test database, or keeps it and logs how to connect when the test failed
+func dropTestDB(t testEnv, baseDB *gorm.DB, config Config, keepOnFailure bool) {
+	if keepOnFailure && t.Failed() {
+		t.Logf("Keeping test database %s for inspection: %s", config.Database, config.PsqlCommand())
+		return
+	}
+	quoted, err := quoteIdent(config.Database)
+	if err != nil {
+		t.Errorf("Failed to drop test database: %v", err)
+		return
+	}
+	if err := baseDB.Exec("DROP DATABASE IF EXISTS " + quoted).Error; err != nil {
+		t.Errorf("Failed to drop test database %s: %v", config.Database, err)
+	}
 }
 
 // CreateTestDB creates isolated test database (backwards compatibility)
@@ -1,7 +1,19 @@
 package dbtesting
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -189,3 +201,592 @@ func TestDatabaseOptions(t *testing.T) {
 		assert.Equal(t, "Cache User 2", found2.Name)
 	})
 }
+
+func TestDisableConnectionCacheForRace(t *testing.T) {
+	DisableConnectionCacheForRace()
+	assert.Equal(t, raceEnabled, connectionCacheDisabled.Load(), "only disabled under -race")
+
+	// Force the uncached mode so the test also runs without -race
+	connectionCacheDisabled.Store(true)
+	t.Cleanup(func() { connectionCacheDisabled.Store(false) })
+
+	connString := GetConfig(EnvTest).ConnString()
+	db1, err := getBaseDB(context.Background(), t, connString)
+	require.NoError(t, err)
+	db2, err := getBaseDB(context.Background(), t, connString)
+	require.NoError(t, err)
+
+	sqlDB1, err := db1.DB()
+	require.NoError(t, err)
+	sqlDB2, err := db2.DB()
+	require.NoError(t, err)
+	assert.NotSame(t, sqlDB1, sqlDB2, "each call should open its own pool")
+
+	// Test databases still work on top of uncached connections
+	db := CreateTestDB(t, EnvTest, DBDebugOff)
+	require.NoError(t, db.Exec("SELECT 1").Error)
+}
+
+func TestDBWithExtensions(t *testing.T) {
+	// Skips automatically if pgcrypto is not installed on the server
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithExtensions("pgcrypto"))
+
+	var id string
+	err := db.Raw("SELECT gen_random_uuid()::text").Row().Scan(&id)
+	require.NoError(t, err)
+	assert.Len(t, id, 36)
+}
+
+func TestDBDeferredInTx(t *testing.T) {
+	// Constraint checks can only be deferred when declared DEFERRABLE
+	createDeferrableTables := func(db *gorm.DB) error {
+		return db.Exec(`
+			CREATE TABLE parents (id BIGINT PRIMARY KEY);
+			CREATE TABLE children (
+				id BIGINT PRIMARY KEY,
+				parent_id BIGINT NOT NULL REFERENCES parents(id) DEFERRABLE INITIALLY IMMEDIATE
+			);
+		`).Error
+	}
+
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBDeferredInTx, DBWithHook(createDeferrableTables))
+
+	// Child inserted before its parent exists succeeds while checks are deferred
+	err := db.Exec("INSERT INTO children (id, parent_id) VALUES (1, 42)").Error
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.Table("children").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// The violation surfaces when the wrapping transaction tries to commit
+	err = db.Commit().Error
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "foreign key")
+}
+
+// failedT simulates a failed test and captures log output
+type failedT struct {
+	*testing.T
+	logs []string
+}
+
+func (f *failedT) Failed() bool { return true }
+
+func (f *failedT) Logf(format string, args ...any) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+// failingEnv is a standalone test env whose test has failed; it captures log output
+type failingEnv struct {
+	standaloneEnv
+	logs []string
+}
+
+func (f *failingEnv) Failed() bool { return true }
+
+func (f *failingEnv) Logf(format string, args ...any) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+// erroringT captures reported errors instead of failing the test
+type erroringT struct {
+	*testing.T
+	errors []string
+}
+
+func (e *erroringT) Errorf(format string, args ...any) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+func TestDropReportsErrors(t *testing.T) {
+	// SQLite has neither DROP DATABASE nor DROP SCHEMA, so both drops fail
+	db, cleanup, err := NewTestDB(EnvTest, DBDebugOff, DBWithDriver(DriverSQLite))
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	t.Run("Database", func(t *testing.T) {
+		et := &erroringT{T: t}
+		config := GetConfig(EnvTest)
+		config.Database = "test_db_1234567"
+		dropTestDB(et, db, config, false)
+
+		require.Len(t, et.errors, 1)
+		assert.Contains(t, et.errors[0], "Failed to drop test database test_db_1234567")
+	})
+
+	t.Run("Schema", func(t *testing.T) {
+		et := &erroringT{T: t}
+		dropTestSchema(et, db, "test_1234567")
+
+		require.Len(t, et.errors, 1)
+		assert.Contains(t, et.errors[0], "Failed to drop test schema test_1234567")
+	})
+}
+
+func TestDBKeepOnFailure(t *testing.T) {
+	config := GetConfig(EnvTest)
+	config.Database = "test_db_1234567"
+
+	t.Run("Logs psql command for retained database", func(t *testing.T) {
+		ft := &failedT{T: t}
+		dropTestDB(ft, nil, config, true)
+
+		require.Len(t, ft.logs, 1)
+		assert.Contains(t, ft.logs[0], "Keeping test database test_db_1234567")
+		assert.Regexp(t,
+			regexp.MustCompile(`PGPASSWORD=\S+ psql -h localhost -p 5432 -U postgres -d test_db_1234567$`),
+			ft.logs[0])
+	})
+
+	t.Run("Shell-quotes unsafe values", func(t *testing.T) {
+		quoted := config
+		quoted.Password = `it's $ecret`
+		assert.Equal(t, `PGPASSWORD='it'\''s $ecret' psql -h localhost -p 5432 -U postgres -d test_db_1234567`, quoted.PsqlCommand())
+	})
+
+	t.Run("Database is kept after a failed test", func(t *testing.T) {
+		env := &failingEnv{}
+		db, err := newTestDB(env, EnvTest, DBDebugOff, DBKeepOnFailure)
+		require.NoError(t, err)
+
+		var name string
+		require.NoError(t, db.Raw("SELECT current_database()").Row().Scan(&name))
+		env.close()
+
+		admin, err := getBaseDB(context.Background(), t, GetConfig(EnvTest).ConnString())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			assert.NoError(t, admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, name)).Error)
+		})
+
+		var exists bool
+		require.NoError(t, admin.Raw("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = ?)", name).Row().Scan(&exists))
+		assert.True(t, exists, "database %s must be kept for inspection", name)
+		assert.Contains(t, strings.Join(env.logs, "\n"), "Keeping test database "+name)
+	})
+}
+
+func TestDBEnsureDatabase(t *testing.T) {
+	base := GetConfig(EnvTest)
+	admin, err := getBaseDB(context.Background(), t, base.ConnString())
+	require.NoError(t, err)
+
+	var canCreateDB bool
+	err = admin.Raw("SELECT rolsuper OR rolcreatedb FROM pg_roles WHERE rolname = current_user").Row().Scan(&canCreateDB)
+	require.NoError(t, err)
+	if !canCreateDB {
+		t.Skip("Current user can't create databases")
+	}
+
+	config := base
+	config.Database = fmt.Sprintf("dev_db_%d", rand.Intn(10000000))
+	// Registered first so it runs last; the dev connection isn't closed by CreateTestDB, hence FORCE
+	t.Cleanup(func() {
+		admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s" WITH (FORCE)`, config.Database))
+	})
+
+	db := CreateTestDB(t, EnvDev, DBDebugOff, DBWithConfig(config), DBEnsureDatabase)
+	require.NotNil(t, db, "dev database should be created instead of skipping")
+
+	var name string
+	require.NoError(t, db.Raw("SELECT current_database()").Row().Scan(&name))
+	assert.Equal(t, config.Database, name)
+
+	require.NoError(t, db.AutoMigrate(&User{}))
+	require.NoError(t, db.Create(&User{Name: "Dev User"}).Error)
+
+	// Existing databases are left alone
+	require.NoError(t, ensureDatabase(context.Background(), config))
+}
+
+func TestDBEnsureDatabaseErrors(t *testing.T) {
+	t.Run("Unreachable server skips", func(t *testing.T) {
+		config := GetConfig(EnvDev)
+		config.Port = 1 // nothing listens here
+		_, err := newTestDB(t, EnvDev, DBDebugOff, DBWithConfig(config), DBEnsureDatabase)
+		assert.ErrorIs(t, err, ErrDatabaseUnavailable)
+	})
+
+	t.Run("Other failures fail the test", func(t *testing.T) {
+		config := GetConfig(EnvDev)
+		config.Database = strings.Repeat("d", 64) // rejected before connecting
+		_, err := newTestDB(t, EnvDev, DBDebugOff, DBWithConfig(config), DBEnsureDatabase)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrDatabaseUnavailable)
+	})
+}
+
+func TestDBCollectHookErrors(t *testing.T) {
+	var ran []int
+	hooks := []func(*gorm.DB) error{
+		func(*gorm.DB) error { ran = append(ran, 1); return errors.New("missing table accounts") },
+		func(*gorm.DB) error { ran = append(ran, 2); return nil },
+		func(*gorm.DB) error { ran = append(ran, 3); return errors.New("seed data conflicts") },
+	}
+
+	t.Run("Stops at the first error by default", func(t *testing.T) {
+		ran = nil
+		err := runPostInitHooks(t, nil, hooks, false)
+		require.Error(t, err)
+		assert.Equal(t, "post-init hook 1: missing table accounts", err.Error())
+		assert.Equal(t, []int{1}, ran)
+	})
+
+	t.Run("Collects every error", func(t *testing.T) {
+		ran = nil
+		err := runPostInitHooks(t, nil, hooks, true)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "post-init hook 1: missing table accounts")
+		assert.Contains(t, err.Error(), "post-init hook 3: seed data conflicts")
+		assert.Equal(t, []int{1, 2, 3}, ran)
+	})
+
+	t.Run("Passing hooks with the option", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBCollectHookErrors, DBWithHook(func(db *gorm.DB) error {
+			return db.AutoMigrate(&User{})
+		}))
+		require.NoError(t, db.Create(&User{Name: "Hooked"}).Error)
+	})
+}
+
+func TestDBWithParentTx(t *testing.T) {
+	// Package-level style setup: one database and one outer transaction shared by subtests
+	parent := CreateTestDB(t, EnvTest, DBDebugOff)
+	require.NoError(t, parent.AutoMigrate(&User{}))
+	require.NoError(t, parent.Create(&User{Name: "Shared"}).Error)
+
+	countUsers := func(t *testing.T, db *gorm.DB) int64 {
+		var n int64
+		require.NoError(t, db.Model(&User{}).Count(&n).Error)
+		return n
+	}
+
+	t.Run("First subtest writes inside its savepoint", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBWithParentTx(parent), DBWithHook(func(db *gorm.DB) error {
+			return db.Exec("CREATE TABLE scratch (id INT)").Error
+		}))
+		require.NoError(t, db.Create(&User{Name: "Nested"}).Error)
+		assert.Equal(t, int64(2), countUsers(t, db))
+	})
+
+	t.Run("Second subtest does not see the first's writes", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBWithParentTx(parent))
+		assert.Equal(t, int64(1), countUsers(t, db))
+
+		var exists bool
+		require.NoError(t, db.Raw("SELECT to_regclass('scratch') IS NOT NULL").Row().Scan(&exists))
+		assert.False(t, exists, "hook changes should be rolled back with the savepoint")
+	})
+}
+
+func TestSQLDB(t *testing.T) {
+	t.Run("Transaction-wrapped handle returns the pool", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff)
+
+		sqlDB := SQLDB(t, db)
+		require.NoError(t, sqlDB.Ping())
+
+		// Derived sessions share the transaction, so they resolve to the same pool
+		assert.Same(t, sqlDB, SQLDB(t, db.Model(&User{})))
+
+		// The pool is outside the test transaction
+		require.NoError(t, db.AutoMigrate(&User{}))
+		var exists bool
+		require.NoError(t, sqlDB.QueryRow("SELECT to_regclass('users') IS NOT NULL").Scan(&exists))
+		assert.False(t, exists, "uncommitted table should not be visible from the pool")
+	})
+
+	t.Run("Unwrapped handle returns its own pool", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBNoWrapInTransaction)
+
+		sqlDB := SQLDB(t, db)
+		require.NoError(t, sqlDB.Ping())
+		sqlDB.SetMaxOpenConns(5)
+		assert.Equal(t, 5, sqlDB.Stats().MaxOpenConnections)
+	})
+}
+
+func TestDBWithDriverSQLite(t *testing.T) {
+	migrate := DBWithHook(func(db *gorm.DB) error { return db.AutoMigrate(&User{}) })
+
+	t.Run("Hooks, fixtures and wrapping run on SQLite", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), migrate,
+			DBWithFixtures(Fixture{Table: "users", Rows: []map[string]any{{"name": "Fixture"}}}))
+		assert.Equal(t, "sqlite", db.Dialector.Name())
+
+		require.NoError(t, db.Create(&User{Name: "Created"}).Error)
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Each test gets a fresh database", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), migrate)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("Pooled connections share the database without wrapping", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), DBNoWrapInTransaction, migrate)
+
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		sqlDB.SetMaxIdleConns(0) // Force a new connection per statement
+
+		require.NoError(t, db.Create(&User{Name: "Committed"}).Error)
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestNewTestDB(t *testing.T) {
+	migrate := DBWithHook(func(db *gorm.DB) error { return db.AutoMigrate(&User{}) })
+
+	t.Run("Returns a usable database and releases it on cleanup", func(t *testing.T) {
+		db, cleanup, err := NewTestDB(EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), migrate)
+		require.NoError(t, err)
+
+		require.NoError(t, db.Create(&User{Name: "Alice"}).Error)
+
+		cleanup()
+		cleanup() // Safe to call twice
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		assert.Error(t, sqlDB.Ping(), "connection should be closed after cleanup")
+	})
+
+	t.Run("Cleanup drops the Postgres database", func(t *testing.T) {
+		db, cleanup, err := NewTestDB(EnvTest, DBDebugOff, migrate)
+		require.NoError(t, err)
+
+		var name string
+		require.NoError(t, db.Raw("SELECT current_database()").Scan(&name).Error)
+		cleanup()
+
+		baseDB, err := getBaseDB(context.Background(), t, GetConfig(EnvTest).ConnString())
+		require.NoError(t, err)
+		var count int64
+		require.NoError(t, baseDB.Raw("SELECT COUNT(*) FROM pg_database WHERE datname = ?", name).Scan(&count).Error)
+		assert.Zero(t, count, "test database should be dropped on cleanup")
+	})
+
+	t.Run("Returns hook errors instead of failing", func(t *testing.T) {
+		failing := DBWithHook(func(db *gorm.DB) error { return errors.New("boom") })
+
+		db, cleanup, err := NewTestDB(EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), failing)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "boom")
+		assert.Nil(t, db)
+		assert.Nil(t, cleanup)
+	})
+
+	t.Run("Unknown environment is an error", func(t *testing.T) {
+		_, _, err := NewTestDB(Env(42))
+		assert.ErrorContains(t, err, "unknown environment")
+	})
+}
+
+func TestDBWithContext(t *testing.T) {
+	migrate := DBWithHook(func(db *gorm.DB) error { return db.AutoMigrate(&User{}) })
+
+	t.Run("Deadline stops connecting to a hung server", func(t *testing.T) {
+		// Accepts connections but never answers the startup handshake
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		accepted := make(chan net.Conn, 16)
+		t.Cleanup(func() {
+			listener.Close()
+			for len(accepted) > 0 {
+				(<-accepted).Close()
+			}
+		})
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- conn
+			}
+		}()
+
+		config := GetConfig(EnvTest)
+		config.Host = "127.0.0.1"
+		config.Port = listener.Addr().(*net.TCPAddr).Port
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, _, err = NewTestDB(EnvTest, DBDebugOff, DBWithConfig(config), DBWithContext(ctx))
+		require.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("Cancelled context fails setup", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, _, err := NewTestDB(EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), DBWithContext(ctx), migrate)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("Cancelling after CREATE DATABASE still drops it", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var name string
+		cancelInHook := DBWithHook(func(db *gorm.DB) error {
+			if err := db.Raw("SELECT current_database()").Row().Scan(&name); err != nil {
+				return err
+			}
+			cancel()
+			return ctx.Err()
+		})
+		_, _, err := NewTestDB(EnvTest, DBDebugOff, DBWithContext(ctx), cancelInHook)
+		require.ErrorIs(t, err, context.Canceled)
+		require.True(t, strings.HasPrefix(name, "test_db_"), "hook ran in the test database, got %q", name)
+
+		admin, err := getBaseDB(context.Background(), t, GetConfig(EnvTest).ConnString())
+		require.NoError(t, err)
+		var exists bool
+		require.NoError(t, admin.Raw("SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = ?)", name).Row().Scan(&exists))
+		assert.False(t, exists, "test database %s must be dropped after a cancelled setup", name)
+	})
+
+	t.Run("Returned handle outlives the setup context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite), DBWithContext(ctx), migrate)
+		cancel()
+
+		require.NoError(t, db.Create(&User{Name: "Alice"}).Error)
+	})
+}
+
+func TestDBWithTemplate(t *testing.T) {
+	base := GetConfig(EnvTest)
+	admin, err := getBaseDB(context.Background(), t, base.ConnString())
+	require.NoError(t, err)
+
+	template := fmt.Sprintf("test_template_%d", rand.Intn(10000000))
+	t.Cleanup(func() {
+		admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s" WITH (FORCE)`, template))
+	})
+
+	var hookRuns int
+	options := []DBOption{
+		DBDebugOff,
+		DBWithTemplate(template),
+		DBWithHook(func(db *gorm.DB) error {
+			hookRuns++
+			return db.AutoMigrate(&User{})
+		}),
+		DBWithFixtures(Fixture{Table: "users", Rows: []map[string]any{{"name": "Template User"}}}),
+	}
+
+	t.Run("Clones carry the template's schema and fixtures", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			db := CreateTestDB(t, EnvTest, options...)
+
+			var count int64
+			require.NoError(t, db.Model(&User{}).Count(&count).Error)
+			assert.Equal(t, int64(1), count)
+			require.NoError(t, db.Create(&User{Name: "Test User"}).Error)
+		}
+		assert.Equal(t, 1, hookRuns, "hooks should only run to build the template")
+	})
+
+	t.Run("Clones are isolated from each other", func(t *testing.T) {
+		first := CreateTestDB(t, EnvTest, append(options, DBNoWrapInTransaction)...)
+		require.NoError(t, first.Create(&User{Name: "Committed"}).Error)
+
+		second := CreateTestDB(t, EnvTest, options...)
+		var count int64
+		require.NoError(t, second.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Open template connections don't block cloning", func(t *testing.T) {
+		config := base
+		config.Database = template
+		lingering, err := openBaseDB(context.Background(), config.ConnString())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			if sqlDB, err := lingering.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+
+		db := CreateTestDB(t, EnvTest, options...)
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
+
+func TestDBWithSchemaIsolation(t *testing.T) {
+	admin, err := getBaseDB(context.Background(), t, GetConfig(EnvTest).ConnString())
+	require.NoError(t, err)
+
+	migrate := DBWithHook(func(db *gorm.DB) error { return db.AutoMigrate(&User{}) })
+	schemaExists := func(schema string) bool {
+		var exists bool
+		require.NoError(t, admin.Raw("SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = ?)", schema).Row().Scan(&exists))
+		return exists
+	}
+
+	var schema string
+	t.Run("Tables land in the test schema", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithSchemaIsolation, migrate)
+
+		require.NoError(t, db.Raw("SELECT current_schema()").Row().Scan(&schema))
+		assert.Regexp(t, `^test_\d+$`, schema)
+
+		var table string
+		require.NoError(t, db.Raw("SELECT table_schema FROM information_schema.tables WHERE table_name = 'users' AND table_schema = ?", schema).Row().Scan(&table))
+		require.NoError(t, db.Create(&User{Name: "Alice"}).Error)
+	})
+
+	t.Run("Schema is dropped on cleanup", func(t *testing.T) {
+		require.NotEmpty(t, schema)
+		assert.False(t, schemaExists(schema))
+	})
+
+	t.Run("Tests don't see each other's rows", func(t *testing.T) {
+		first := CreateTestDB(t, EnvTest, DBDebugOff, DBWithSchemaIsolation, DBNoWrapInTransaction, migrate)
+		require.NoError(t, first.Create(&User{Name: "Committed"}).Error)
+
+		second := CreateTestDB(t, EnvTest, DBDebugOff, DBWithSchemaIsolation, migrate)
+		var count int64
+		require.NoError(t, second.Model(&User{}).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+}
+
+func TestDBWithGormConfig(t *testing.T) {
+	t.Run("Naming strategy applies to AutoMigrate and queries", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite),
+			DBWithGormConfig(func(c *gorm.Config) {
+				c.NamingStrategy = schema.NamingStrategy{TablePrefix: "app_", SingularTable: true}
+			}),
+			DBWithHook(func(db *gorm.DB) error { return db.AutoMigrate(&User{}) }),
+		)
+
+		assert.True(t, db.Migrator().HasTable("app_user"))
+		require.NoError(t, db.Create(&User{Name: "Alice"}).Error)
+
+		var count int64
+		require.NoError(t, db.Table("app_user").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Logger can be overridden", func(t *testing.T) {
+		db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithDriver(DriverSQLite),
+			DBWithGormConfig(func(c *gorm.Config) { c.Logger = logger.Discard }))
+		assert.Equal(t, logger.Discard, db.Logger)
+	})
+}
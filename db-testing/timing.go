@@ -0,0 +1,119 @@
+package dbtesting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueryTiming is a statement run by the test with how long it took
+type QueryTiming struct {
+	SQL      string // Statement with placeholders, so repeated queries (N+1s) look alike
+	Duration time.Duration
+}
+
+// DBWithTimingReport records every query's duration and logs the topN slowest statements on cleanup
+// Only queries run by the test are timed (not hooks or fixtures). Helps spot accidental N+1s and slow queries
+func DBWithTimingReport(topN int) DBOption {
+	return func(o *dbOptions) {
+		o.TimingReportTopN = topN
+	}
+}
+
+const (
+	// timingCallbackName is the gorm callback pair timing statements
+	timingCallbackName = "dbtesting:timing"
+	// queryTimerKey stores the *queryTimer in the handle's settings
+	queryTimerKey = "dbtesting:query_timer"
+	// timingStartKey stores a statement's start time on its instance
+	timingStartKey = "dbtesting:timing_start"
+)
+
+// queryTimer collects query timings of one test
+type queryTimer struct {
+	mu      sync.Mutex
+	timings []QueryTiming
+}
+
+// slowest returns the n slowest timings, slowest first
+func (qt *queryTimer) slowest(n int) []QueryTiming {
+	qt.mu.Lock()
+	timings := append([]QueryTiming{}, qt.timings...)
+	qt.mu.Unlock()
+
+	sort.SliceStable(timings, func(i, j int) bool { return timings[i].Duration > timings[j].Duration })
+	if len(timings) > n {
+		timings = timings[:n]
+	}
+	return timings
+}
+
+// withTimingReport returns db timing its queries, logging the slowest on cleanup
+func withTimingReport(t testEnv, db *gorm.DB, topN int) (*gorm.DB, error) {
+	if err := registerTimingCallbacks(db); err != nil {
+		return nil, fmt.Errorf("failed to register timing callbacks: %w", err)
+	}
+
+	timer := &queryTimer{}
+	t.Cleanup(func() {
+		slowest := timer.slowest(topN)
+		t.Logf("Slowest %d queries:", len(slowest))
+		for i, timing := range slowest {
+			t.Logf("  %d. %s  %s", i+1, timing.Duration, timing.SQL)
+		}
+	})
+	return db.Set(queryTimerKey, timer).Session(&gorm.Session{}), nil
+}
+
+// queryTimings returns the timer of a DBWithTimingReport handle, or nil
+func queryTimings(db *gorm.DB) *queryTimer {
+	timer, _ := db.Get(queryTimerKey)
+	qt, _ := timer.(*queryTimer)
+	return qt
+}
+
+// registerTimingCallbacks adds the timing callbacks to db once; they are no-ops on handles without a timer
+func registerTimingCallbacks(db *gorm.DB) error {
+	cb := db.Callback()
+	if cb.Query().Get(timingCallbackName+":before") != nil {
+		return nil
+	}
+
+	start := func(db *gorm.DB) {
+		db.InstanceSet(timingStartKey, time.Now())
+	}
+	stop := func(db *gorm.DB) {
+		timer := queryTimings(db)
+		started, ok := db.InstanceGet(timingStartKey)
+		if timer == nil || !ok {
+			return
+		}
+		timing := QueryTiming{SQL: db.Statement.SQL.String(), Duration: time.Since(started.(time.Time))}
+		timer.mu.Lock()
+		timer.timings = append(timer.timings, timing)
+		timer.mu.Unlock()
+	}
+
+	for _, register := range []func() error{
+		func() error { return cb.Create().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Create().After("*").Register(timingCallbackName+":after", stop) },
+		func() error { return cb.Query().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Query().After("*").Register(timingCallbackName+":after", stop) },
+		func() error { return cb.Update().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Update().After("*").Register(timingCallbackName+":after", stop) },
+		func() error { return cb.Delete().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Delete().After("*").Register(timingCallbackName+":after", stop) },
+		func() error { return cb.Row().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Row().After("*").Register(timingCallbackName+":after", stop) },
+		func() error { return cb.Raw().Before("*").Register(timingCallbackName+":before", start) },
+		func() error { return cb.Raw().After("*").Register(timingCallbackName+":after", stop) },
+	} {
+		if err := register(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
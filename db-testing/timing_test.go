@@ -0,0 +1,39 @@
+package dbtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBWithTimingReport(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithTimingReport(3))
+
+	timer := queryTimings(db)
+	require.NotNil(t, timer, "handle should carry the query timer")
+
+	require.NoError(t, db.Exec("SELECT pg_sleep(0.05)").Error)
+	require.NoError(t, db.Exec("SELECT pg_sleep(0.01)").Error)
+	require.NoError(t, db.Exec("SELECT pg_sleep(0.03)").Error)
+	for i := 0; i < 5; i++ {
+		var n int
+		require.NoError(t, db.Raw("SELECT ?::int", i).Scan(&n).Error)
+	}
+
+	slowest := timer.slowest(3)
+	require.Len(t, slowest, 3)
+	assert.Contains(t, slowest[0].SQL, "pg_sleep(0.05)")
+	assert.Contains(t, slowest[1].SQL, "pg_sleep(0.03)")
+	assert.Contains(t, slowest[2].SQL, "pg_sleep(0.01)")
+	for i := 1; i < len(slowest); i++ {
+		assert.GreaterOrEqual(t, slowest[i-1].Duration, slowest[i].Duration, "sorted slowest first")
+	}
+
+	assert.Len(t, timer.slowest(100), 8, "every query is recorded")
+}
+
+func TestDBWithTimingReportOff(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff)
+	assert.Nil(t, queryTimings(db))
+}
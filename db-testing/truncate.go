@@ -0,0 +1,58 @@
+package dbtesting
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// versionTables are migration bookkeeping tables that TruncateAll leaves untouched
+var versionTables = map[string]bool{
+	"goose_db_version":          true,
+	"goose_migration_checksums": true,
+	"schema_migrations":         true,
+}
+
+// TruncateAll empties every user table in one TRUNCATE ... RESTART IDENTITY CASCADE statement
+// CASCADE satisfies foreign keys regardless of order; migration version tables are skipped
+// Works on any *gorm.DB, not only databases created by CreateTestDB
+func TruncateAll(db *gorm.DB) error {
+	var tables []struct {
+		Schema string
+		Name   string
+	}
+	err := db.Raw(`
+		SELECT schemaname AS schema, tablename AS name
+		FROM pg_tables
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+		ORDER BY schemaname, tablename
+	`).Scan(&tables).Error
+	if err != nil {
+		return fmt.Errorf("failed to list tables: %w", err)
+	}
+
+	var names []string
+	for _, table := range tables {
+		if versionTables[table.Name] {
+			continue
+		}
+		schema, err := quoteIdent(table.Schema)
+		if err != nil {
+			return err
+		}
+		name, err := quoteIdent(table.Name)
+		if err != nil {
+			return err
+		}
+		names = append(names, schema+"."+name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	if err := db.Exec("TRUNCATE TABLE " + strings.Join(names, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		return fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package dbtesting
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTruncateAll(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff, DBWithHook(createParentChildHook), DBWithHook(func(db *gorm.DB) error {
+		return db.Exec(`
+			CREATE TABLE goose_db_version (
+				id SERIAL PRIMARY KEY,
+				version_id BIGINT NOT NULL,
+				is_applied BOOLEAN NOT NULL
+			);
+			INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true), (1, true);
+		`).Error
+	}))
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	require.NoError(t, db.Exec("INSERT INTO parents (id, name) VALUES (1, 'Parent')").Error)
+	require.NoError(t, db.Exec("INSERT INTO children (id, parent_id, name) VALUES (10, 1, 'Child')").Error)
+	require.NoError(t, db.Create(&User{Name: "Alice"}).Error)
+
+	require.NoError(t, TruncateAll(db))
+
+	count := func(table string) int64 {
+		var n int64
+		require.NoError(t, db.Table(table).Count(&n).Error)
+		return n
+	}
+	assert.Zero(t, count("parents"))
+	assert.Zero(t, count("children"))
+	assert.Zero(t, count("users"))
+	assert.Equal(t, int64(2), count("goose_db_version"), "version table must survive")
+
+	// Identities restart
+	AssertNextID(t, db, "users", 1)
+	user := User{Name: "Bob"}
+	require.NoError(t, db.Create(&user).Error)
+	assert.Equal(t, uint(1), user.ID)
+}
+
+func TestAssertNextID(t *testing.T) {
+	db := CreateTestDB(t, EnvTest, DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	AssertNextID(t, db, "users", 1)
+
+	require.NoError(t, db.Create(&[]User{{Name: "Alice"}, {Name: "Bob"}}).Error)
+	AssertNextID(t, db, "users", 3)
+	// Peeking doesn't consume the id
+	AssertNextID(t, db, "users", 3)
+
+	require.NoError(t, db.Exec("TRUNCATE TABLE users RESTART IDENTITY").Error)
+	AssertNextID(t, db, "users", 1)
+
+	ft := &fatalT{T: t}
+	AssertNextID(ft, db, "users", 5)
+	assert.Contains(t, ft.fatal, "Expected next id of users to be 5, got 1")
+}
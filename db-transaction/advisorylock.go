@@ -0,0 +1,18 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+)
+
+// AdvisoryLock takes a Postgres transaction-level advisory lock on key (pg_advisory_xact_lock)
+// Blocks until the lock is available; it is released automatically when the transaction commits or rolls back,
+// so unlike session locks it can't leak on a pooled connection. Coordinates work across app instances.
+// Returns ErrNoTransaction if the context has no transaction
+func AdvisoryLock(ctx context.Context, key int64) error {
+	tx := GetTx(ctx)
+	if tx == nil {
+		return fmt.Errorf("advisory lock %d: %w", key, ErrNoTransaction)
+	}
+	return tx.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(?)", key).Error
+}
@@ -0,0 +1,61 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdvisoryLock(t *testing.T) {
+	// Contending transactions need separate connections, so don't wrap the test DB in a transaction
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+
+	const hold = 300 * time.Millisecond
+
+	t.Run("Requires a transaction", func(t *testing.T) {
+		assert.ErrorIs(t, AdvisoryLock(context.Background(), 1), ErrNoTransaction)
+	})
+
+	// contend holds firstKey for a while in one transaction and returns how long locking secondKey took in another
+	contend := func(t *testing.T, firstKey, secondKey int64) time.Duration {
+		locked := make(chan struct{})
+		errs := make(chan error, 1)
+		go func() {
+			errs <- RunInTx(context.Background(), db, func(ctx context.Context) error {
+				err := AdvisoryLock(ctx, firstKey)
+				close(locked)
+				if err != nil {
+					return err
+				}
+				time.Sleep(hold)
+				return nil
+			})
+		}()
+		<-locked
+
+		var waited time.Duration
+		require.NoError(t, RunInTx(context.Background(), db, func(ctx context.Context) error {
+			start := time.Now()
+			err := AdvisoryLock(ctx, secondKey)
+			waited = time.Since(start)
+			return err
+		}))
+		require.NoError(t, <-errs)
+		return waited
+	}
+
+	t.Run("Same key serializes", func(t *testing.T) {
+		waited := contend(t, 42, 42)
+		assert.GreaterOrEqual(t, waited, hold/2, "second transaction should wait for the first to finish")
+	})
+
+	t.Run("Different keys proceed in parallel", func(t *testing.T) {
+		waited := contend(t, 42, 43)
+		assert.Less(t, waited, hold/2)
+	})
+}
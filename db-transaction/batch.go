@@ -0,0 +1,35 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// BatchedTx runs fn for every item, committing a transaction every batchSize items to bound transaction
+// size and lock duration in large loads (e.g. ETL). Each batch runs in its own RunInTx, so hooks registered
+// with RegisterAfterCommit fire once per committed batch
+// On error the failing batch is rolled back and processing stops; earlier batches stay committed
+// Inside a context transaction the batches are savepoints and nothing commits until the outer transaction does
+func BatchedTx[T any](ctx context.Context, db *gorm.DB, batchSize int, items []T, fn func(ctx context.Context, item T) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	for start := 0; start < len(items); start += batchSize {
+		batch := items[start:min(start+batchSize, len(items))]
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			for _, item := range batch {
+				if err := fn(ctx, item); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("batch of items %d-%d rolled back: %w", start, start+len(batch)-1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchedTx(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	items := make([]int, 1000)
+	for i := range items {
+		items[i] = i
+	}
+
+	countUsers := func() int64 {
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		return count
+	}
+
+	t.Run("Commits every batch", func(t *testing.T) {
+		commits := 0
+		err := BatchedTx(ctx, db, 100, items, func(ctx context.Context, i int) error {
+			if i%100 == 0 {
+				if err := RegisterAfterCommit(ctx, func() { commits++ }); err != nil {
+					return err
+				}
+			}
+			return repo.CreateUser(ctx, &User{Name: fmt.Sprintf("user-%d", i)})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 10, commits)
+		assert.Equal(t, int64(1000), countUsers())
+	})
+
+	require.NoError(t, db.Where("1 = 1").Delete(&User{}).Error)
+
+	t.Run("Rolls back only the failing batch", func(t *testing.T) {
+		commits := 0
+		processed := 0
+		err := BatchedTx(ctx, db, 100, items, func(ctx context.Context, i int) error {
+			processed++
+			if i == 550 {
+				return assert.AnError
+			}
+			if i%100 == 0 {
+				if err := RegisterAfterCommit(ctx, func() { commits++ }); err != nil {
+					return err
+				}
+			}
+			return repo.CreateUser(ctx, &User{Name: fmt.Sprintf("user-%d", i)})
+		})
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Contains(t, err.Error(), "items 500-599")
+		assert.Equal(t, 5, commits, "hooks of the failed batch must not fire")
+		assert.Equal(t, 551, processed, "later batches must not run")
+		assert.Equal(t, int64(500), countUsers())
+	})
+
+	t.Run("Rejects a non-positive batch size", func(t *testing.T) {
+		err := BatchedTx(ctx, db, 0, items, func(ctx context.Context, i int) error { return nil })
+		assert.Error(t, err)
+	})
+}
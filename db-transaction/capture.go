@@ -0,0 +1,125 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// SQLError wraps a transaction error with the last statement that failed inside it
+type SQLError struct {
+	SQL  string
+	Vars []any // Bound values; strings are redacted when the statement touches secret-looking columns
+	Err  error
+}
+
+func (e *SQLError) Error() string {
+	return fmt.Sprintf("%v (sql: %s, vars: %v)", e.Err, e.SQL, e.Vars)
+}
+
+func (e *SQLError) Unwrap() error {
+	return e.Err
+}
+
+// sqlCaptureKey is used to store the failed statement recorder in the context
+var sqlCaptureKey = new(int)
+
+// sqlCapture records the last failed statement of a transaction
+type sqlCapture struct {
+	mu   sync.Mutex
+	sql  string
+	vars []any
+}
+
+// captureCallbackName is the gorm callback recording failed statements
+const captureCallbackName = "transaction:capture_failed_sql"
+
+// captureCallbacksMu serializes callback registration across runners
+var captureCallbacksMu sync.Mutex
+
+// ensureCaptureCallbacks registers the failed statement callback on db once
+// Callbacks are shared by every session of the same *gorm.DB, so later calls are no-ops
+func ensureCaptureCallbacks(db *gorm.DB) error {
+	captureCallbacksMu.Lock()
+	defer captureCallbacksMu.Unlock()
+
+	cb := db.Callback()
+	if cb.Create().Get(captureCallbackName) != nil {
+		return nil
+	}
+
+	for _, register := range []func() error{
+		func() error { return cb.Create().After("*").Register(captureCallbackName, captureFailedSQL) },
+		func() error { return cb.Query().After("*").Register(captureCallbackName, captureFailedSQL) },
+		func() error { return cb.Update().After("*").Register(captureCallbackName, captureFailedSQL) },
+		func() error { return cb.Delete().After("*").Register(captureCallbackName, captureFailedSQL) },
+		func() error { return cb.Row().After("*").Register(captureCallbackName, captureFailedSQL) },
+		func() error { return cb.Raw().After("*").Register(captureCallbackName, captureFailedSQL) },
+	} {
+		if err := register(); err != nil {
+			return fmt.Errorf("failed to register SQL capture callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// captureFailedSQL records the statement when it failed inside a capturing runner
+// Not-found lookups are skipped: they are usually handled and shouldn't be blamed for a later error
+func captureFailedSQL(db *gorm.DB) {
+	if db.Error == nil || errors.Is(db.Error, gorm.ErrRecordNotFound) || db.Statement.Context == nil {
+		return
+	}
+	capture, _ := db.Statement.Context.Value(sqlCaptureKey).(*sqlCapture)
+	if capture == nil || db.Statement.SQL.Len() == 0 {
+		return
+	}
+
+	sql := db.Statement.SQL.String()
+	capture.mu.Lock()
+	defer capture.mu.Unlock()
+	capture.sql = sql
+	capture.vars = redactVars(sql, db.Statement.Vars)
+}
+
+// secretColumnPattern matches column names that obviously hold secrets
+var secretColumnPattern = regexp.MustCompile(`(?i)password|passwd|secret|token|api_?key|credential`)
+
+// redactVars replaces string values with a placeholder when the statement references secret-looking columns
+// Vars can't be reliably mapped back to columns, so all strings are redacted in that case
+func redactVars(sql string, vars []any) []any {
+	redacted := make([]any, len(vars))
+	copy(redacted, vars)
+	if !secretColumnPattern.MatchString(sql) {
+		return redacted
+	}
+	for i, v := range redacted {
+		switch v.(type) {
+		case string, []byte:
+			redacted[i] = "[REDACTED]"
+		}
+	}
+	return redacted
+}
+
+// withSQLCapture adds a failed statement recorder to the context
+func withSQLCapture(ctx context.Context) (context.Context, *sqlCapture) {
+	capture := &sqlCapture{}
+	return context.WithValue(ctx, sqlCaptureKey, capture), capture
+}
+
+// wrap returns err wrapped with the recorded statement, or err unchanged if nothing failed
+func (c *sqlCapture) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sql == "" {
+		return err
+	}
+	return &SQLError{SQL: c.sql, Vars: c.vars, Err: err}
+}
@@ -0,0 +1,101 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// Credential has a secret column to check redaction
+type Credential struct {
+	ID       uint   `gorm:"primaryKey"`
+	Username string `gorm:"uniqueIndex;not null"`
+	Password string
+}
+
+func TestCaptureFailedSQL(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&Product{}, &Credential{}))
+	require.NoError(t, db.Create(&Product{SKU: "SKU-1", Name: "Existing", Price: 100}).Error)
+	require.NoError(t, db.Create(&Credential{Username: "alice", Password: "hunter2"}).Error)
+
+	dbFunc := GetTxOrDefault(db)
+
+	t.Run("Wraps constraint violation with failing statement", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&Product{SKU: "SKU-2", Name: "Fine", Price: 1}).Error; err != nil {
+				return err
+			}
+			return dbFunc(ctx).Create(&Product{SKU: "SKU-1", Name: "Duplicate", Price: 200}).Error
+		}, CaptureFailedSQL)
+		require.Error(t, err)
+
+		var sqlErr *SQLError
+		require.ErrorAs(t, err, &sqlErr)
+		assert.Contains(t, sqlErr.SQL, `INSERT INTO "products"`)
+		assert.Contains(t, sqlErr.Vars, "Duplicate")
+		assert.Contains(t, err.Error(), `INSERT INTO "products"`)
+
+		// The driver error is still reachable
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		assert.Equal(t, "23505", pgErr.Code)
+	})
+
+	t.Run("Redacts secret values", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return dbFunc(ctx).Create(&Credential{Username: "alice", Password: "s3cret-value"}).Error
+		}, CaptureFailedSQL)
+
+		var sqlErr *SQLError
+		require.ErrorAs(t, err, &sqlErr)
+		assert.Contains(t, sqlErr.SQL, `INSERT INTO "credentials"`)
+		assert.NotContains(t, err.Error(), "s3cret-value")
+		assert.Contains(t, sqlErr.Vars, "[REDACTED]")
+	})
+
+	t.Run("Errors not caused by SQL are returned as is", func(t *testing.T) {
+		errBusiness := errors.New("business rule violated")
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return errBusiness
+		}, CaptureFailedSQL)
+		assert.Same(t, errBusiness, err)
+	})
+
+	t.Run("Handled not-found lookups are not captured", func(t *testing.T) {
+		errBusiness := errors.New("business rule violated")
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			var product Product
+			if err := dbFunc(ctx).Where("sku = ?", "missing").First(&product).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			return errBusiness
+		}, CaptureFailedSQL)
+		assert.Same(t, errBusiness, err)
+	})
+
+	t.Run("Without option errors are not wrapped", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return dbFunc(ctx).Create(&Product{SKU: "SKU-1", Name: "Duplicate", Price: 200}).Error
+		})
+		require.Error(t, err)
+		var sqlErr *SQLError
+		assert.False(t, errors.As(err, &sqlErr))
+	})
+}
+
+func TestRedactVars(t *testing.T) {
+	vars := []any{"alice", "hunter2", 42}
+
+	assert.Equal(t, vars, redactVars(`INSERT INTO "users" ("name","email","age") VALUES ($1,$2,$3)`, vars))
+	assert.Equal(t, []any{"[REDACTED]", "[REDACTED]", 42},
+		redactVars(`INSERT INTO "credentials" ("username","password","age") VALUES ($1,$2,$3)`, vars))
+	assert.Equal(t, "hunter2", vars[1], "input vars must not be modified")
+}
@@ -0,0 +1,67 @@
+package transaction
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrWriteOutsideTx is returned for writes rejected by the EnforceTransaction plugin
+var ErrWriteOutsideTx = errors.New("write outside a transaction")
+
+// EnforceTransaction returns a gorm plugin that rejects writes whose context holds no transaction (see GetTx)
+// Creates, updates, deletes and Exec statements fail with ErrWriteOutsideTx; reads are allowed.
+// Install it with db.Use(EnforceTransaction()) in services where every write must go through RunInTx,
+// to catch accidental non-transactional writes at runtime. WithoutTx writes are rejected too,
+// so intentional ones need a separate *gorm.DB without the plugin
+func EnforceTransaction() gorm.Plugin {
+	return enforceTxPlugin{}
+}
+
+// enforceTxPlugin implements gorm.Plugin
+type enforceTxPlugin struct{}
+
+// enforceTxCallbackName is the plugin name and the name of its callbacks
+const enforceTxCallbackName = "transaction:enforce_transaction"
+
+func (enforceTxPlugin) Name() string {
+	return enforceTxCallbackName
+}
+
+func (enforceTxPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, register := range []func() error{
+		func() error {
+			return cb.Create().Before("gorm:begin_transaction").Register(enforceTxCallbackName, rejectWriteOutsideTx)
+		},
+		func() error {
+			return cb.Update().Before("gorm:begin_transaction").Register(enforceTxCallbackName, rejectWriteOutsideTx)
+		},
+		func() error {
+			return cb.Delete().Before("gorm:begin_transaction").Register(enforceTxCallbackName, rejectWriteOutsideTx)
+		},
+		func() error { return cb.Raw().Before("gorm:raw").Register(enforceTxCallbackName, rejectWriteOutsideTx) },
+	} {
+		if err := register(); err != nil {
+			return fmt.Errorf("failed to register enforce transaction callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// rejectWriteOutsideTx fails the statement when its context has no transaction
+func rejectWriteOutsideTx(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	if ctx := db.Statement.Context; ctx != nil && GetTx(ctx) != nil {
+		return
+	}
+
+	target := db.Statement.Table
+	if target == "" {
+		target = db.Statement.SQL.String()
+	}
+	db.AddError(fmt.Errorf("%w: %s", ErrWriteOutsideTx, target))
+}
@@ -0,0 +1,53 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnforceTransaction(t *testing.T) {
+	// The plugin checks the context, so the test DB's own wrapping transaction doesn't count
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	require.NoError(t, db.Create(&User{Name: "Existing", Balance: 10}).Error)
+	require.NoError(t, db.Use(EnforceTransaction()))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	t.Run("Writes outside a transaction fail", func(t *testing.T) {
+		assert.ErrorIs(t, repo.CreateUser(ctx, &User{Name: "Alice"}), ErrWriteOutsideTx)
+		assert.ErrorIs(t, db.WithContext(ctx).Model(&User{}).Where("name = ?", "Existing").Update("balance", 20).Error, ErrWriteOutsideTx)
+		assert.ErrorIs(t, db.WithContext(ctx).Where("name = ?", "Existing").Delete(&User{}).Error, ErrWriteOutsideTx)
+		assert.ErrorIs(t, db.WithContext(ctx).Exec("UPDATE users SET balance = 0").Error, ErrWriteOutsideTx)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("name = ?", "Alice").Count(&count).Error)
+		assert.Zero(t, count, "rejected insert must not be executed")
+	})
+
+	t.Run("Reads outside a transaction are allowed", func(t *testing.T) {
+		var users []User
+		require.NoError(t, db.WithContext(ctx).Find(&users).Error)
+		assert.Len(t, users, 1)
+	})
+
+	t.Run("The same writes inside RunInTx succeed", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := repo.CreateUser(ctx, &User{Name: "Alice"}); err != nil {
+				return err
+			}
+			return repo.UpdateBalance(ctx, 1, 5)
+		})
+		require.NoError(t, err)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("name = ?", "Alice").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -2,6 +2,7 @@ package transaction
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -18,6 +19,12 @@ type Account struct {
 	Balance int64  `gorm:"default:0"`
 }
 
+// Banking errors callers can match with errors.Is
+var (
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrAccountNotFound   = errors.New("account not found")
+)
+
 // AccountRepository handles account data operations
 type AccountRepository struct {
 	db func(ctx context.Context) *gorm.DB
@@ -30,6 +37,11 @@ func NewAccountRepository(db *gorm.DB) *AccountRepository {
 	}
 }
 
+// DBFunc returns the db function the repository queries through
+func (r AccountRepository) DBFunc() func(ctx context.Context) *gorm.DB {
+	return r.db
+}
+
 func (r *AccountRepository) CreateAccount(ctx context.Context, account *Account) error {
 	return r.db(ctx).Create(account).Error
 }
@@ -37,7 +49,13 @@ func (r *AccountRepository) CreateAccount(ctx context.Context, account *Account)
 func (r *AccountRepository) GetAccount(ctx context.Context, id uint) (*Account, error) {
 	var account Account
 	err := r.db(ctx).First(&account, id).Error
-	return &account, err
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("account %d: %w", id, ErrAccountNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
 }
 
 func (r *AccountRepository) UpdateBalance(ctx context.Context, id uint, newBalance int64) error {
@@ -76,7 +94,7 @@ func (s *BankingService) TransferMoney(ctx context.Context, fromID, toID uint, a
 
 		// Business logic validation
 		if fromAccount.Balance < amount {
-			return fmt.Errorf("insufficient balance: has %d, needs %d", fromAccount.Balance, amount)
+			return fmt.Errorf("%w: has %d, needs %d", ErrInsufficientFunds, fromAccount.Balance, amount)
 		}
 
 		// Update balances
@@ -93,21 +111,24 @@ func (s *BankingService) TransferMoney(ctx context.Context, fromID, toID uint, a
 }
 
 // CreateAccountWithInitialDeposit creates account and sets initial balance atomically
+// RunInTxR returns the created account directly instead of capturing an outer variable
 func (s *BankingService) CreateAccountWithInitialDeposit(ctx context.Context, name string, initialBalance int64) (*Account, error) {
-	var account *Account
-
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		ctx = SetTx(ctx, tx)
-
-		account = &Account{
+	return RunInTxR(ctx, s.db, func(ctx context.Context) (*Account, error) {
+		account := &Account{
 			Name:    name,
 			Balance: initialBalance,
 		}
+		if err := s.accRepo.CreateAccount(ctx, account); err != nil {
+			return nil, err
+		}
 
-		return s.accRepo.CreateAccount(ctx, account)
-	})
+		// Validated after insert to demonstrate rollback of the created row
+		if initialBalance < 0 {
+			return nil, fmt.Errorf("initial deposit must not be negative: %d", initialBalance)
+		}
 
-	return account, err
+		return account, nil
+	})
 }
 
 // TestBankingTransactionExample demonstrates the complete banking transaction pattern
@@ -162,8 +183,7 @@ func TestBankingTransactionExample(t *testing.T) {
 
 		// Attempt transfer with insufficient funds (should fail and rollback)
 		err = bankingService.TransferMoney(ctx, charlie.ID, dave.ID, 1000)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "insufficient balance")
+		require.ErrorIs(t, err, ErrInsufficientFunds)
 
 		// Verify balances didn't change (transaction rolled back)
 		finalCharlie, err := bankingService.accRepo.GetAccount(ctx, charlie.ID)
@@ -175,6 +195,47 @@ func TestBankingTransactionExample(t *testing.T) {
 		require.Equal(t, initialDave, finalDave.Balance)
 	})
 
+	t.Run("Transfer To Missing Account", func(t *testing.T) {
+		heidi, err := bankingService.CreateAccountWithInitialDeposit(ctx, "Heidi", 400)
+		require.NoError(t, err)
+
+		err = bankingService.TransferMoney(ctx, heidi.ID, 999999, 100)
+		require.ErrorIs(t, err, ErrAccountNotFound)
+		require.NotErrorIs(t, err, ErrInsufficientFunds)
+
+		// Balance unchanged
+		finalHeidi, err := bankingService.accRepo.GetAccount(ctx, heidi.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(400), finalHeidi.Balance)
+
+		_, err = bankingService.accRepo.GetAccount(ctx, 999999)
+		require.ErrorIs(t, err, ErrAccountNotFound)
+	})
+
+	t.Run("Create Account Returns Generated ID", func(t *testing.T) {
+		frank, err := bankingService.CreateAccountWithInitialDeposit(ctx, "Frank", 300)
+		require.NoError(t, err)
+		require.NotZero(t, frank.ID)
+
+		found, err := bankingService.accRepo.GetAccount(ctx, frank.ID)
+		require.NoError(t, err)
+		require.Equal(t, "Frank", found.Name)
+	})
+
+	t.Run("Create Account Returns Nil On Rollback", func(t *testing.T) {
+		var before int64
+		require.NoError(t, db.Model(&Account{}).Count(&before).Error)
+
+		grace, err := bankingService.CreateAccountWithInitialDeposit(ctx, "Grace", -1)
+		require.Error(t, err)
+		require.Nil(t, grace)
+
+		// The inserted row was rolled back
+		var after int64
+		require.NoError(t, db.Model(&Account{}).Count(&after).Error)
+		require.Equal(t, before, after)
+	})
+
 	t.Run("Repository Works Without Transaction", func(t *testing.T) {
 		// Repository methods work fine without transactions too
 		eve := &Account{Name: "Eve", Balance: 750}
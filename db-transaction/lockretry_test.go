@@ -0,0 +1,75 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLockRetry(t *testing.T) {
+	// Concurrent transactions need committed data, so don't wrap the test DB in a transaction
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	user := User{Name: "Contended", Balance: 100}
+	require.NoError(t, db.Create(&user).Error)
+
+	dbFunc := GetTxOrDefault(db)
+
+	// holdLock locks the row in another transaction for d and returns once the lock is taken
+	holdLock := func(t *testing.T, d time.Duration) <-chan error {
+		locked := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- RunInTx(context.Background(), db, func(ctx context.Context) error {
+				return WithLockedRow(ctx, dbFunc, user.ID, func(u *User) error {
+					close(locked)
+					time.Sleep(d)
+					u.Balance += 10
+					return nil
+				})
+			})
+		}()
+		<-locked
+		return done
+	}
+
+	t.Run("Acquires the lock once released", func(t *testing.T) {
+		done := holdLock(t, 200*time.Millisecond)
+
+		ctx := WithLockRetry(context.Background(), 20, 20*time.Millisecond)
+		var seen int64
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			return WithLockedRow(ctx, dbFunc, user.ID, func(u *User) error {
+				seen = u.Balance
+				u.Balance += 10
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		require.NoError(t, <-done)
+		assert.Equal(t, int64(110), seen, "should see the holder's committed change")
+	})
+
+	t.Run("Gives up with ErrLockTimeout", func(t *testing.T) {
+		done := holdLock(t, 500*time.Millisecond)
+
+		ctx := WithLockRetry(context.Background(), 3, 10*time.Millisecond)
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			err := Mutate(ctx, dbFunc, user.ID, func(u *User) error { return nil })
+			require.ErrorIs(t, err, ErrLockTimeout)
+
+			// Failed attempts were rolled back to a savepoint, so the transaction is still usable
+			var count int64
+			require.NoError(t, dbFunc(ctx).Model(&User{}).Count(&count).Error)
+			return err
+		})
+		assert.ErrorIs(t, err, ErrLockTimeout)
+		require.NoError(t, <-done)
+	})
+}
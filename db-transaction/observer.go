@@ -0,0 +1,176 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// TxObserver is notified about transactions run by RunInTx with WithObserver
+type TxObserver interface {
+	// OnCommitSummary is called after the transaction committed (or its savepoint was released when nested)
+	OnCommitSummary(summary TxSummary)
+}
+
+// TxSummary lists the rows a transaction wrote, per table
+// Only gorm Create/Update/Delete through the runner's context are counted; raw Exec statements are not
+type TxSummary struct {
+	Tables map[string]TableChanges
+}
+
+// TableChanges counts affected rows per operation
+type TableChanges struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+}
+
+// WithObserver reports a summary of the written rows to obs when the transaction commits
+// Installs gorm callbacks on the DB on first use, e.g. to build an audit trail without per-repository code
+func WithObserver(obs TxObserver) TxOption {
+	return func(o *txOptions) {
+		o.Observer = obs
+	}
+}
+
+// writeRecorderKey is used to store the write recorder in the context
+var writeRecorderKey = new(int)
+
+// writeRecorder accumulates affected rows of a transaction scope
+// A nested scope's counts are merged into the parent recorder only when it commits (its savepoint is released),
+// so writes that were rolled back don't show up in outer totals
+type writeRecorder struct {
+	mu     sync.Mutex
+	tables map[string]TableChanges
+	parent *writeRecorder
+}
+
+// withWriteRecorder adds a write recorder to the context, nested in the context's recorder if any
+func withWriteRecorder(ctx context.Context) (context.Context, *writeRecorder) {
+	parent, _ := ctx.Value(writeRecorderKey).(*writeRecorder)
+	recorder := &writeRecorder{tables: map[string]TableChanges{}, parent: parent}
+	return context.WithValue(ctx, writeRecorderKey, recorder), recorder
+}
+
+// nestWriteRecorder adds a write recorder for a transaction scope when the context is already tracked
+// Returns a nil recorder and ctx unchanged otherwise
+func nestWriteRecorder(ctx context.Context) (context.Context, *writeRecorder) {
+	if _, ok := ctx.Value(writeRecorderKey).(*writeRecorder); !ok {
+		return ctx, nil
+	}
+	return withWriteRecorder(ctx)
+}
+
+// add applies a change to the table counts of r
+func (r *writeRecorder) add(table string, apply func(c *TableChanges)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := r.tables[table]
+	apply(&changes)
+	r.tables[table] = changes
+}
+
+// committed merges r's counts into its parent once r's scope committed
+func (r *writeRecorder) committed() {
+	if r.parent == nil {
+		return
+	}
+	for table, changes := range r.summary().Tables {
+		r.parent.add(table, func(c *TableChanges) {
+			c.Inserted += changes.Inserted
+			c.Updated += changes.Updated
+			c.Deleted += changes.Deleted
+		})
+	}
+}
+
+// TrackRowEffects returns a context that counts the rows written through it (and contexts derived from it)
+// Pass it to RunInTx and read the totals afterwards with RowEffects, e.g. for a response payload.
+// Installs the same gorm callbacks as WithObserver; writes of transactions and nested RunInTx calls
+// that roll back are not counted
+func TrackRowEffects(ctx context.Context, db *gorm.DB) (context.Context, error) {
+	if err := ensureObserveCallbacks(db); err != nil {
+		return ctx, err
+	}
+	ctx, _ = withWriteRecorder(ctx)
+	return ctx, nil
+}
+
+// RowEffects returns the rows inserted, updated and deleted so far in a TrackRowEffects context, across all tables
+// Returns zero counts when ctx isn't tracked
+func RowEffects(ctx context.Context) TableChanges {
+	recorder, _ := ctx.Value(writeRecorderKey).(*writeRecorder)
+	if recorder == nil {
+		return TableChanges{}
+	}
+
+	var total TableChanges
+	for _, changes := range recorder.summary().Tables {
+		total.Inserted += changes.Inserted
+		total.Updated += changes.Updated
+		total.Deleted += changes.Deleted
+	}
+	return total
+}
+
+// summary returns a copy of the recorded changes
+func (r *writeRecorder) summary() TxSummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tables := make(map[string]TableChanges, len(r.tables))
+	for table, changes := range r.tables {
+		tables[table] = changes
+	}
+	return TxSummary{Tables: tables}
+}
+
+// observeCallbackName is the gorm callback counting written rows
+const observeCallbackName = "transaction:observe_writes"
+
+// observeCallbacksMu serializes callback registration across runners
+var observeCallbacksMu sync.Mutex
+
+// ensureObserveCallbacks registers the write counting callbacks on db once
+func ensureObserveCallbacks(db *gorm.DB) error {
+	observeCallbacksMu.Lock()
+	defer observeCallbacksMu.Unlock()
+
+	cb := db.Callback()
+	if cb.Create().Get(observeCallbackName) != nil {
+		return nil
+	}
+
+	for _, register := range []func() error{
+		func() error {
+			return cb.Create().After("*").Register(observeCallbackName, recordWrite(func(c *TableChanges, n int64) { c.Inserted += n }))
+		},
+		func() error {
+			return cb.Update().After("*").Register(observeCallbackName, recordWrite(func(c *TableChanges, n int64) { c.Updated += n }))
+		},
+		func() error {
+			return cb.Delete().After("*").Register(observeCallbackName, recordWrite(func(c *TableChanges, n int64) { c.Deleted += n }))
+		},
+	} {
+		if err := register(); err != nil {
+			return fmt.Errorf("failed to register observer callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordWrite returns a callback adding the statement's affected rows to the context recorder
+func recordWrite(add func(c *TableChanges, n int64)) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Error != nil || db.Statement.Context == nil || db.Statement.Table == "" {
+			return
+		}
+		recorder, _ := db.Statement.Context.Value(writeRecorderKey).(*writeRecorder)
+		if recorder == nil {
+			return
+		}
+
+		recorder.add(db.Statement.Table, func(c *TableChanges) { add(c, db.RowsAffected) })
+	}
+}
@@ -0,0 +1,139 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingObserver keeps every summary it receives
+type recordingObserver struct {
+	summaries []TxSummary
+}
+
+func (o *recordingObserver) OnCommitSummary(summary TxSummary) {
+	o.summaries = append(o.summaries, summary)
+}
+
+func TestWithObserver(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}, &AuditLog{}))
+
+	dbFunc := GetTxOrDefault(db)
+
+	t.Run("Summarizes writes on commit", func(t *testing.T) {
+		obs := &recordingObserver{}
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			users := []User{{Name: "Alice", Balance: 100}, {Name: "Bob", Balance: 50}, {Name: "Carol"}}
+			if err := dbFunc(ctx).Create(&users).Error; err != nil {
+				return err
+			}
+			if err := dbFunc(ctx).Model(&User{}).Where("balance > 0").Update("balance", 0).Error; err != nil {
+				return err
+			}
+			if err := dbFunc(ctx).Delete(&User{}, users[2].ID).Error; err != nil {
+				return err
+			}
+			return dbFunc(ctx).Create(&AuditLog{Action: "reset balances"}).Error
+		}, WithObserver(obs))
+		require.NoError(t, err)
+
+		require.Len(t, obs.summaries, 1)
+		assert.Equal(t, map[string]TableChanges{
+			"users":      {Inserted: 3, Updated: 2, Deleted: 1},
+			"audit_logs": {Inserted: 1},
+		}, obs.summaries[0].Tables)
+	})
+
+	t.Run("No summary on rollback", func(t *testing.T) {
+		obs := &recordingObserver{}
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&User{Name: "Rolled Back"}).Error; err != nil {
+				return err
+			}
+			return assert.AnError
+		}, WithObserver(obs))
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, obs.summaries)
+	})
+
+	t.Run("Writes outside the runner are not counted", func(t *testing.T) {
+		obs := &recordingObserver{}
+		require.NoError(t, RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return dbFunc(context.Background()).Create(&AuditLog{Action: "outside"}).Error
+		}, WithObserver(obs)))
+
+		require.Len(t, obs.summaries, 1)
+		assert.Empty(t, obs.summaries[0].Tables)
+	})
+}
+
+func TestRowEffects(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}, &AuditLog{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx, err := TrackRowEffects(context.Background(), db)
+	require.NoError(t, err)
+
+	obs := &recordingObserver{}
+	err = RunInTx(ctx, db, func(ctx context.Context) error {
+		if err := dbFunc(ctx).Create(&[]User{{Name: "Alice"}, {Name: "Bob"}}).Error; err != nil {
+			return err
+		}
+		if err := dbFunc(ctx).Model(&User{}).Where("name = ?", "Alice").Update("balance", 10).Error; err != nil {
+			return err
+		}
+		// Writes in a nested runner count towards both scopes
+		return RunInTx(ctx, db, func(ctx context.Context) error {
+			return dbFunc(ctx).Create(&AuditLog{Action: "created users"}).Error
+		}, WithObserver(obs))
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, TableChanges{Inserted: 3, Updated: 1}, RowEffects(ctx))
+	require.Len(t, obs.summaries, 1)
+	assert.Equal(t, map[string]TableChanges{"audit_logs": {Inserted: 1}}, obs.summaries[0].Tables)
+
+	assert.Equal(t, TableChanges{}, RowEffects(context.Background()))
+
+	t.Run("Rolled back writes are not counted", func(t *testing.T) {
+		ctx, err := TrackRowEffects(context.Background(), db)
+		require.NoError(t, err)
+
+		obs := &recordingObserver{}
+		err = RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&User{Name: "Carol"}).Error; err != nil {
+				return err
+			}
+			// The nested runner rolls back to its savepoint; the outer transaction carries on
+			nestedErr := RunInTx(ctx, db, func(ctx context.Context) error {
+				if err := dbFunc(ctx).Create(&AuditLog{Action: "discarded"}).Error; err != nil {
+					return err
+				}
+				return assert.AnError
+			})
+			require.ErrorIs(t, nestedErr, assert.AnError)
+			return nil
+		}, WithObserver(obs))
+		require.NoError(t, err)
+
+		assert.Equal(t, TableChanges{Inserted: 1}, RowEffects(ctx))
+		require.Len(t, obs.summaries, 1)
+		assert.Equal(t, map[string]TableChanges{"users": {Inserted: 1}}, obs.summaries[0].Tables)
+
+		// A transaction that rolls back adds nothing
+		err = RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&User{Name: "Dave"}).Error; err != nil {
+				return err
+			}
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, TableChanges{Inserted: 1}, RowEffects(ctx))
+	})
+}
@@ -0,0 +1,120 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxMessage is an event written in the same transaction as the change it describes
+// A drainer publishes it afterwards, so the event is sent if and only if the change committed
+type OutboxMessage struct {
+	ID          uint   `gorm:"primaryKey"`
+	Topic       string `gorm:"not null"`
+	Payload     []byte
+	CreatedAt   time.Time
+	LockedUntil *time.Time `gorm:"index"` // Lease of the drainer currently publishing the message
+	ProcessedAt *time.Time `gorm:"index"` // Set once published
+}
+
+// ErrLeaseLost is returned when a message's lease expired and another drainer reclaimed it before it was marked processed
+// The message is then published again by that drainer (delivery is at-least-once)
+var ErrLeaseLost = errors.New("outbox lease expired before message was processed")
+
+// EnqueueOutbox stores a message in the outbox using the context transaction
+// Returns ErrNoTransaction outside a transaction: the message must commit atomically with the change
+func EnqueueOutbox(ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, topic string, payload []byte) error {
+	if GetTx(ctx) == nil {
+		return fmt.Errorf("EnqueueOutbox: %w", ErrNoTransaction)
+	}
+	return dbFunc(ctx).Create(&OutboxMessage{Topic: topic, Payload: payload}).Error
+}
+
+// DrainOutbox claims up to batchSize unprocessed messages, publishes them in id order and marks them processed
+// Claimed rows are leased for the lease duration (locked_until, database clock) and selected with
+// FOR UPDATE SKIP LOCKED, so concurrent drainers never claim the same row while its lease is valid.
+// If a drainer crashes or its context is cancelled, its leases expire and another drainer reclaims the rows;
+// the lease must therefore be longer than publishing a batch takes.
+// Returns the number of messages published; stops at the first publish error, leaving the rest to expire
+func DrainOutbox(ctx context.Context, db *gorm.DB, batchSize int, lease time.Duration, publish func(ctx context.Context, msg OutboxMessage) error) (int, error) {
+	messages, err := claimOutbox(ctx, db, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, msg := range messages {
+		if err := ctx.Err(); err != nil {
+			return published, err
+		}
+		if err := publish(ctx, msg); err != nil {
+			return published, fmt.Errorf("failed to publish outbox message %d: %w", msg.ID, err)
+		}
+		if err := completeOutbox(ctx, db, msg); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+// claimOutbox leases unprocessed messages that are unclaimed or whose lease expired
+func claimOutbox(ctx context.Context, db *gorm.DB, batchSize int, lease time.Duration) ([]OutboxMessage, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	table, err := outboxTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []OutboxMessage
+	err = db.WithContext(ctx).Raw(`
+		UPDATE ? SET locked_until = now() + make_interval(secs => ?)
+		WHERE id IN (
+			SELECT id FROM ?
+			WHERE processed_at IS NULL AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, table, lease.Seconds(), table, batchSize).Scan(&messages).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	// RETURNING doesn't preserve the subquery order
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+// outboxTable resolves the OutboxMessage table through db's naming strategy (TablePrefix, TableName),
+// so the raw claim query hits the same table EnqueueOutbox writes to
+func outboxTable(db *gorm.DB) (clause.Table, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&OutboxMessage{}); err != nil {
+		return clause.Table{}, fmt.Errorf("failed to resolve outbox table: %w", err)
+	}
+	return clause.Table{Name: stmt.Schema.Table}, nil
+}
+
+// completeOutbox marks msg processed unless another drainer reclaimed it since (its lease changed)
+func completeOutbox(ctx context.Context, db *gorm.DB, msg OutboxMessage) error {
+	result := db.WithContext(ctx).Model(&OutboxMessage{}).
+		Where("id = ? AND locked_until = ?", msg.ID, msg.LockedUntil).
+		Updates(map[string]any{"processed_at": gorm.Expr("now()"), "locked_until": nil})
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark outbox message %d processed: %w", msg.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("outbox message %d: %w", msg.ID, ErrLeaseLost)
+	}
+	return nil
+}
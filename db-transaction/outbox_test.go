@@ -0,0 +1,150 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestOutbox(t *testing.T) {
+	// Drainers use separate connections, so the test DB must not be wrapped in a transaction
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+	require.NoError(t, db.AutoMigrate(&User{}, &OutboxMessage{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	collect := func(topics *[]string) func(ctx context.Context, msg OutboxMessage) error {
+		var mu sync.Mutex
+		return func(ctx context.Context, msg OutboxMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			*topics = append(*topics, msg.Topic)
+			return nil
+		}
+	}
+	reset := func(t *testing.T) {
+		require.NoError(t, db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&OutboxMessage{}).Error)
+	}
+
+	t.Run("Enqueue requires a transaction", func(t *testing.T) {
+		err := EnqueueOutbox(ctx, dbFunc, "user.created", nil)
+		assert.ErrorIs(t, err, ErrNoTransaction)
+	})
+
+	t.Run("Messages commit and roll back with the change", func(t *testing.T) {
+		reset(t)
+		require.NoError(t, RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&User{Name: "Alice"}).Error; err != nil {
+				return err
+			}
+			return EnqueueOutbox(ctx, dbFunc, "user.created", []byte(`{"name":"Alice"}`))
+		}))
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, EnqueueOutbox(ctx, dbFunc, "user.rolled_back", nil))
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+
+		var topics []string
+		n, err := DrainOutbox(ctx, db, 10, time.Minute, collect(&topics))
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"user.created"}, topics)
+
+		// Processed messages are not published again
+		n, err = DrainOutbox(ctx, db, 10, time.Minute, collect(&topics))
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("Drains the table of a custom naming strategy", func(t *testing.T) {
+		// A separate instance: parsed schemas are cached per gorm config
+		prefixed, err := gorm.Open(db.Dialector, &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{TablePrefix: "app_"},
+			Logger:         db.Logger,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			if sqlDB, err := prefixed.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		require.NoError(t, prefixed.AutoMigrate(&OutboxMessage{}))
+
+		require.NoError(t, RunInTx(ctx, prefixed, func(ctx context.Context) error {
+			return EnqueueOutbox(ctx, GetTxOrDefault(prefixed), "user.prefixed", nil)
+		}))
+
+		var topics []string
+		n, err := DrainOutbox(ctx, prefixed, 10, time.Minute, collect(&topics))
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"user.prefixed"}, topics)
+	})
+
+	t.Run("Expired lease of a crashed drainer is reclaimed", func(t *testing.T) {
+		reset(t)
+		require.NoError(t, RunInTx(ctx, db, func(ctx context.Context) error {
+			return EnqueueOutbox(ctx, dbFunc, "order.placed", nil)
+		}))
+
+		// Crashed drainer: claimed the row, never published it
+		crashed, err := claimOutbox(ctx, db, 10, 300*time.Millisecond)
+		require.NoError(t, err)
+		require.Len(t, crashed, 1)
+
+		var topics []string
+		n, err := DrainOutbox(ctx, db, 10, time.Minute, collect(&topics))
+		require.NoError(t, err)
+		assert.Zero(t, n, "row is leased until the timeout")
+
+		time.Sleep(400 * time.Millisecond)
+		n, err = DrainOutbox(ctx, db, 10, time.Minute, collect(&topics))
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"order.placed"}, topics)
+
+		// The crashed drainer can't complete a message it no longer owns
+		assert.ErrorIs(t, completeOutbox(ctx, db, crashed[0]), ErrLeaseLost)
+	})
+
+	t.Run("Concurrent drainers publish each message once", func(t *testing.T) {
+		reset(t)
+		require.NoError(t, RunInTx(ctx, db, func(ctx context.Context) error {
+			for i := 0; i < 50; i++ {
+				if err := EnqueueOutbox(ctx, dbFunc, "bulk", nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+
+		var topics []string
+		publish := collect(&topics)
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					n, err := DrainOutbox(ctx, db, 5, time.Minute, publish)
+					if !assert.NoError(t, err) || n == 0 {
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, topics, 50)
+	})
+}
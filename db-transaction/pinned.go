@@ -0,0 +1,36 @@
+package transaction
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// pinnedConnKey is used to store the pinned connection in the context
+// It is kept apart from ctxKey: a pinned connection is in autocommit mode, not a transaction
+var pinnedConnKey = new(int)
+
+// WithPinnedConn runs fn with a single pooled connection injected into the context
+// Repositories using GetTxOrDefault then all run on that connection, e.g. for LISTEN/NOTIFY or
+// session variables (SET app.user_id = ...) that must be visible to later queries in the scope.
+// GetTx still returns nil inside the scope, and RunInTx begins its transactions on the pinned connection.
+// The connection is returned to the pool afterwards, so reset session state fn changed (RESET ...).
+// Inside an existing transaction fn simply runs on it, since a transaction is already one connection
+func WithPinnedConn(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error) error {
+	if GetTx(ctx) != nil {
+		return fn(ctx)
+	}
+
+	return db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		return fn(context.WithValue(ctx, pinnedConnKey, conn))
+	})
+}
+
+// getPinnedConn retrieves the connection pinned by WithPinnedConn
+// Returns nil outside a pinned scope
+func getPinnedConn(ctx context.Context) *gorm.DB {
+	if conn, ok := ctx.Value(pinnedConnKey).(*gorm.DB); ok {
+		return conn
+	}
+	return nil
+}
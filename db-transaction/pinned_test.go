@@ -0,0 +1,95 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// sessionRepository reads and writes a session variable through the context DB
+type sessionRepository struct {
+	db func(ctx context.Context) *gorm.DB
+}
+
+func (r *sessionRepository) SetUserID(ctx context.Context, id string) error {
+	return r.db(ctx).Exec("SELECT set_config('app.user_id', ?, false)", id).Error
+}
+
+func (r *sessionRepository) UserID(ctx context.Context) (string, error) {
+	var id string
+	err := r.db(ctx).Raw("SELECT current_setting('app.user_id', true)").Row().Scan(&id)
+	return id, err
+}
+
+func (r *sessionRepository) BackendPID(ctx context.Context) (int, error) {
+	var pid int
+	err := r.db(ctx).Raw("SELECT pg_backend_pid()").Row().Scan(&pid)
+	return pid, err
+}
+
+func TestWithPinnedConn(t *testing.T) {
+	// Connection needs the pool, not a wrapping transaction
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+	repo := &sessionRepository{db: GetTxOrDefault(db)}
+
+	t.Run("Session variable is visible to later calls", func(t *testing.T) {
+		err := WithPinnedConn(context.Background(), db, func(ctx context.Context) error {
+			require.NoError(t, repo.SetUserID(ctx, "42"))
+
+			id, err := repo.UserID(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "42", id)
+
+			first, err := repo.BackendPID(ctx)
+			require.NoError(t, err)
+			second, err := repo.BackendPID(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, first, second)
+
+			return repo.db(ctx).Exec("RESET app.user_id").Error
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Transactions run on the pinned connection", func(t *testing.T) {
+		err := WithPinnedConn(context.Background(), db, func(ctx context.Context) error {
+			pinned, err := repo.BackendPID(ctx)
+			require.NoError(t, err)
+
+			return RunInTx(ctx, db, func(ctx context.Context) error {
+				inTx, err := repo.BackendPID(ctx)
+				require.NoError(t, err)
+				assert.Equal(t, pinned, inTx)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Pinned connection is not a transaction", func(t *testing.T) {
+		err := WithPinnedConn(context.Background(), db, func(ctx context.Context) error {
+			assert.Nil(t, GetTx(ctx))
+
+			err := WithLockedRow(ctx, GetTxOrDefault(db), 1, func(u *User) error { return nil })
+			assert.ErrorIs(t, err, ErrNoTransaction)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Reuses an existing transaction", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			tx := GetTx(ctx)
+			return WithPinnedConn(ctx, db, func(ctx context.Context) error {
+				assert.Same(t, tx, GetTx(ctx))
+				return nil
+			})
+		})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,59 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// DBFuncRepository is a repository exposing the db function its queries go through, e.g. the one built with GetTxOrDefault
+type DBFuncRepository interface {
+	DBFunc() func(ctx context.Context) *gorm.DB
+}
+
+// probeConnPool identifies the probe transaction passed to repository db functions; it is never queried
+type probeConnPool struct {
+	gorm.ConnPool
+}
+
+// AssertRepositoriesUseTxContext fails the test for each repository whose db function doesn't return the context transaction
+// A db function built from a captured *gorm.DB or with Fix silently runs outside the caller's transaction and is reported
+// No database is needed: each db function is called with a probe transaction that is never queried
+func AssertRepositoriesUseTxContext(t testing.TB, repos ...DBFuncRepository) {
+	t.Helper()
+
+	for _, repo := range repos {
+		if err := checkRepositoryUsesTxContext(repo); err != nil {
+			t.Errorf("%T: %v", repo, err)
+		}
+	}
+}
+
+// checkRepositoryUsesTxContext calls repo's db function with a probe transaction and checks it comes back
+func checkRepositoryUsesTxContext(repo DBFuncRepository) (err error) {
+	if repo == nil {
+		return errors.New("repository is nil")
+	}
+
+	pool := &probeConnPool{}
+	probe := &gorm.DB{Config: &gorm.Config{}, Statement: &gorm.Statement{ConnPool: pool}}
+	probe.Statement.DB = probe
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("db function panicked with a transaction in context: %v", r)
+		}
+	}()
+	dbFunc := repo.DBFunc()
+	if dbFunc == nil {
+		return errors.New("db function is nil")
+	}
+	got := dbFunc(SetTx(context.Background(), probe))
+	if got == nil || got.Statement == nil || got.Statement.ConnPool != pool {
+		return errors.New("db function ignores the context transaction; build it with GetTxOrDefault")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package transaction
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// rawDBRepository captures a *gorm.DB instead of a db function
+type rawDBRepository struct {
+	db *gorm.DB
+}
+
+func (r *rawDBRepository) DBFunc() func(ctx context.Context) *gorm.DB {
+	return func(ctx context.Context) *gorm.DB { return r.db }
+}
+
+func TestAssertRepositoriesUseTxContext(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "repocheck.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	t.Run("Repositories built with GetTxOrDefault pass", func(t *testing.T) {
+		et := &errorfT{T: t}
+		AssertRepositoriesUseTxContext(et,
+			NewUserRepository(db),
+			AccountRepository{db: GetTxOrDefault(db)}, // By value
+		)
+		assert.Empty(t, et.errors)
+	})
+
+	t.Run("Repository built with Fix fails", func(t *testing.T) {
+		et := &errorfT{T: t}
+		AssertRepositoriesUseTxContext(et, &UserRepository{db: Fix(db)})
+
+		require.Len(t, et.errors, 1)
+		assert.Contains(t, et.errors[0], "*transaction.UserRepository")
+		assert.Contains(t, et.errors[0], "ignores the context transaction")
+	})
+
+	t.Run("Misconfigured repositories are each reported", func(t *testing.T) {
+		et := &errorfT{T: t}
+		AssertRepositoriesUseTxContext(et,
+			&rawDBRepository{db: db},
+			&UserRepository{},
+			nil,
+			NewUserRepository(db),
+		)
+
+		require.Len(t, et.errors, 3)
+		assert.Contains(t, et.errors[0], "*transaction.rawDBRepository: db function ignores the context transaction")
+		assert.Contains(t, et.errors[1], "db function is nil")
+		assert.Contains(t, et.errors[2], "repository is nil")
+	})
+
+}
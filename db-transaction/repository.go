@@ -0,0 +1,315 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StreamRows iterates over query results one row at a time instead of loading them all into memory
+// The query runs on the context transaction when present (see GetTxOrDefault)
+// Iteration stops on the first fn error or when the context is cancelled; rows are always closed
+// Note: fn must not query through the same transaction while rows are open
+func StreamRows[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, query func(*gorm.DB) *gorm.DB, fn func(T) error) error {
+	db := query(dbFunc(ctx).Model(new(T)))
+
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item T
+		if err := db.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// Upsert inserts item or, when it conflicts on conflictColumns, updates only updateColumns
+// With no updateColumns the conflicting insert is skipped (ON CONFLICT DO NOTHING)
+// Runs on the context transaction when present
+func Upsert[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, item *T, conflictColumns []string, updateColumns []string) error {
+	onConflict := clause.OnConflict{}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(updateColumns) == 0 {
+		onConflict.DoNothing = true
+	} else {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+
+	return dbFunc(ctx).Clauses(onConflict).Create(item).Error
+}
+
+// UpdateByIDs applies values to rows with the given ids, chunking the IN clause to stay under parameter limits
+// All chunks run in one transaction (nested in the context transaction when present)
+// Returns the total number of rows affected
+func UpdateByIDs[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, ids []uint, values map[string]any, chunkSize int) (int64, error) {
+	if chunkSize <= 0 {
+		return 0, fmt.Errorf("chunk size must be positive, got %d", chunkSize)
+	}
+
+	var total int64
+	err := dbFunc(ctx).Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(ids); start += chunkSize {
+			end := min(start+chunkSize, len(ids))
+			result := tx.Model(new(T)).Where("id IN ?", ids[start:end]).Updates(values)
+			if result.Error != nil {
+				return result.Error
+			}
+			total += result.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// FindInBatches loads all rows of T in batches of batchSize (ordered by primary key) and passes each batch to fn
+// Runs on the context transaction when present; stops and returns the first fn error
+// The batch slice is reused between calls, so copy it if it must outlive fn
+func FindInBatches[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, batchSize int, fn func(batch []T) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+
+	var batch []T
+	return dbFunc(ctx).FindInBatches(&batch, batchSize, func(_ *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
+}
+
+// WithLockedRow loads the row with the given id FOR UPDATE, runs fn on it and saves the result
+// Must be called inside a transaction (locks are released at statement end otherwise); returns ErrNoTransaction if none
+// Concurrent callers for the same id serialize: the second waits and then sees the first's committed change
+// With a WithLockRetry context the lock is taken with NOWAIT and retried instead of waiting
+func WithLockedRow[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, id uint, fn func(*T) error) error {
+	if GetTx(ctx) == nil {
+		return fmt.Errorf("WithLockedRow: %w", ErrNoTransaction)
+	}
+
+	var row T
+	if err := lockRow(ctx, dbFunc, id, &row); err != nil {
+		return err
+	}
+	if err := fn(&row); err != nil {
+		return err
+	}
+	return dbFunc(ctx).Save(&row).Error
+}
+
+// Mutate is an alias of WithLockedRow for call sites that read as a compare-and-swap without a version column
+func Mutate[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, id uint, mutate func(*T) error) error {
+	return WithLockedRow(ctx, dbFunc, id, mutate)
+}
+
+// FindOrError returns the row of T with the given id, or notFound (wrapped) when there is none
+// Lets repositories return domain errors (e.g. ErrUserNotFound) instead of gorm.ErrRecordNotFound;
+// other errors are returned unchanged. Runs on the context transaction when present
+func FindOrError[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, id uint, notFound error) (*T, error) {
+	var row T
+	err := dbFunc(ctx).First(&row, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("id %d: %w", id, notFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// Latest returns the row of T with the highest orderColumn (e.g. "created_at")
+// orderColumn must be a field of T (column or Go field name), so it is safe to take from user input
+// Returns an error wrapping gorm.ErrRecordNotFound when the table is empty
+func Latest[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, orderColumn string) (*T, error) {
+	return firstOrdered[T](ctx, dbFunc, orderColumn, true)
+}
+
+// Earliest returns the row of T with the lowest orderColumn; see Latest
+func Earliest[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, orderColumn string) (*T, error) {
+	return firstOrdered[T](ctx, dbFunc, orderColumn, false)
+}
+
+// firstOrdered fetches the first row of T ordered by orderColumn after checking it against T's schema
+func firstOrdered[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, orderColumn string, desc bool) (*T, error) {
+	db := dbFunc(ctx)
+
+	model, column, err := modelColumn[T](db, orderColumn)
+	if err != nil {
+		return nil, fmt.Errorf("unknown order column: %w", err)
+	}
+
+	var row T
+	err = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}).First(&row).Error
+	if err != nil {
+		return nil, fmt.Errorf("%s by %s: %w", model, column, err)
+	}
+	return &row, nil
+}
+
+// modelColumn resolves column (column or Go field name) against T's schema
+// Returns the model name and the column's DB name; unknown columns are rejected, so input can come from users
+func modelColumn[T any](db *gorm.DB, column string) (model string, dbName string, err error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return "", "", fmt.Errorf("failed to parse model: %w", err)
+	}
+	field := stmt.Schema.LookUpField(column)
+	if field == nil || field.DBName == "" {
+		return stmt.Schema.Name, "", fmt.Errorf("%q is not a column of %s", column, stmt.Schema.Name)
+	}
+	return stmt.Schema.Name, field.DBName, nil
+}
+
+// SoftDelete marks the row of T with the given id as deleted by setting its deleted_at column
+// T must have a DeletedAt field (gorm.DeletedAt); models without one are rejected instead of hard-deleted
+// Runs on the context transaction when present; returns gorm.ErrRecordNotFound if no visible row matched
+func SoftDelete[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, id uint) error {
+	db := dbFunc(ctx)
+	if err := requireSoftDelete[T](db); err != nil {
+		return err
+	}
+
+	result := db.Delete(new(T), id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Restore clears deleted_at on the soft-deleted row of T with the given id, making it visible again
+// Runs on the context transaction when present; returns gorm.ErrRecordNotFound if no row matched
+func Restore[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, id uint) error {
+	db := dbFunc(ctx)
+	if err := requireSoftDelete[T](db); err != nil {
+		return err
+	}
+
+	result := db.Model(new(T)).Unscoped().Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// requireSoftDelete checks that T has a deleted_at column
+func requireSoftDelete[T any](db *gorm.DB) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(new(T)); err != nil {
+		return fmt.Errorf("failed to parse model: %w", err)
+	}
+	if field := stmt.Schema.LookUpField("deleted_at"); field == nil {
+		return fmt.Errorf("%s has no DeletedAt field for soft delete", stmt.Schema.Name)
+	}
+	return nil
+}
+
+// FilterOp is a comparison operator allowed in a List filter
+type FilterOp string
+
+const (
+	OpEq   FilterOp = "="
+	OpNe   FilterOp = "!="
+	OpGt   FilterOp = ">"
+	OpLt   FilterOp = "<"
+	OpLike FilterOp = "LIKE"
+	OpIn   FilterOp = "IN" // Value must be a slice
+)
+
+// listOps maps allowed filter operators to their SQL
+var listOps = map[FilterOp]string{
+	OpEq:   "=",
+	OpNe:   "<>",
+	OpGt:   ">",
+	OpLt:   "<",
+	OpLike: "LIKE",
+	OpIn:   "IN",
+}
+
+// Filter is one "column op value" condition; filters are ANDed
+type Filter struct {
+	Column string // Column or Go field name of the model
+	Op     FilterOp
+	Value  any
+}
+
+// Sort orders List results; an empty Column orders by primary key
+type Sort struct {
+	Column string
+	Desc   bool
+}
+
+// Page selects a window of List results; a zero Limit returns all rows from Offset
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// List returns the page of T rows matching filters and the total count of matching rows
+// Columns and operators are validated against the model and a whitelist, so filters can come from request params
+// Both queries run on the context transaction when present (see GetTxOrDefault)
+func List[T any](ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, filters []Filter, sort Sort, page Page) ([]T, int64, error) {
+	db := dbFunc(ctx)
+
+	query := db.Model(new(T))
+	for _, f := range filters {
+		_, column, err := modelColumn[T](db, f.Column)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid filter: %w", err)
+		}
+		op, ok := listOps[f.Op]
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid filter: unsupported operator %q", f.Op)
+		}
+		query = query.Where(clause.Expr{SQL: "? " + op + " ?", Vars: []any{clause.Column{Name: column}, f.Value}})
+	}
+
+	order := clause.OrderByColumn{Column: clause.PrimaryColumn, Desc: sort.Desc}
+	if sort.Column != "" {
+		_, column, err := modelColumn[T](db, sort.Column)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid sort: %w", err)
+		}
+		order.Column = clause.Column{Name: column}
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	paged := query.Order(order).Offset(page.Offset)
+	if page.Limit > 0 {
+		paged = paged.Limit(page.Limit)
+	}
+	var rows []T
+	if err := paged.Find(&rows).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list rows: %w", err)
+	}
+	return rows, total, nil
+}
@@ -0,0 +1,570 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedUsers inserts n users named user-1..user-n
+func seedUsers(t *testing.T, db *gorm.DB, n int) {
+	t.Helper()
+	users := make([]User, n)
+	for i := range users {
+		users[i] = User{Name: fmt.Sprintf("user-%d", i+1), Balance: int64(i + 1)}
+	}
+	require.NoError(t, db.CreateInBatches(users, 500).Error)
+}
+
+func TestStreamRows(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	seedUsers(t, db, 1000)
+
+	dbFunc := GetTxOrDefault(db)
+	orderByID := func(db *gorm.DB) *gorm.DB { return db.Order("id") }
+
+	t.Run("Invokes callback for each row", func(t *testing.T) {
+		var count int
+		var total int64
+		err := StreamRows(context.Background(), dbFunc, orderByID, func(u User) error {
+			count++
+			total += u.Balance
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1000, count)
+		assert.Equal(t, int64(1000*1001/2), total)
+	})
+
+	t.Run("Honors query filters within a transaction", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			var names []string
+			err := StreamRows(ctx, dbFunc, func(db *gorm.DB) *gorm.DB {
+				return db.Where("balance <= ?", 3).Order("id")
+			}, func(u User) error {
+				names = append(names, u.Name)
+				return nil
+			})
+			assert.Equal(t, []string{"user-1", "user-2", "user-3"}, names)
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Stops on callback error", func(t *testing.T) {
+		var count int
+		err := StreamRows(context.Background(), dbFunc, orderByID, func(u User) error {
+			count++
+			if count == 5 {
+				return assert.AnError
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("Cancellation stops iteration early", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var count int
+		err := StreamRows(ctx, dbFunc, orderByID, func(u User) error {
+			count++
+			if count == 10 {
+				cancel()
+			}
+			return nil
+		})
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 10, count)
+	})
+}
+
+// Product has a natural key (SKU) for upsert tests
+type Product struct {
+	ID    uint   `gorm:"primaryKey"`
+	SKU   string `gorm:"uniqueIndex;not null"`
+	Name  string
+	Price int64
+}
+
+func TestUpsert(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&Product{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	getBySKU := func(sku string) Product {
+		var p Product
+		require.NoError(t, db.Where("sku = ?", sku).First(&p).Error)
+		return p
+	}
+
+	t.Run("Insert then update specified columns", func(t *testing.T) {
+		require.NoError(t, Upsert(ctx, dbFunc, &Product{SKU: "A-1", Name: "Widget", Price: 100}, []string{"sku"}, []string{"price"}))
+		require.NoError(t, Upsert(ctx, dbFunc, &Product{SKU: "A-1", Name: "Renamed", Price: 150}, []string{"sku"}, []string{"price"}))
+
+		p := getBySKU("A-1")
+		assert.Equal(t, int64(150), p.Price, "price should be updated")
+		assert.Equal(t, "Widget", p.Name, "name should be untouched")
+
+		var count int64
+		require.NoError(t, db.Model(&Product{}).Where("sku = ?", "A-1").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Do nothing on conflict", func(t *testing.T) {
+		require.NoError(t, Upsert(ctx, dbFunc, &Product{SKU: "B-1", Name: "Gadget", Price: 200}, []string{"sku"}, nil))
+		require.NoError(t, Upsert(ctx, dbFunc, &Product{SKU: "B-1", Name: "Other", Price: 999}, []string{"sku"}, nil))
+
+		p := getBySKU("B-1")
+		assert.Equal(t, "Gadget", p.Name)
+		assert.Equal(t, int64(200), p.Price)
+	})
+
+	t.Run("Participates in the context transaction", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := Upsert(ctx, dbFunc, &Product{SKU: "C-1", Name: "Rolled Back"}, []string{"sku"}, nil); err != nil {
+				return err
+			}
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+
+		var count int64
+		require.NoError(t, db.Model(&Product{}).Where("sku = ?", "C-1").Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestUpdateByIDs(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	seedUsers(t, db, 3000)
+
+	var ids []uint
+	require.NoError(t, db.Model(&User{}).Order("id").Pluck("id", &ids).Error)
+	require.Len(t, ids, 3000)
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	t.Run("Updates all chunks and sums affected rows", func(t *testing.T) {
+		affected, err := UpdateByIDs[User](ctx, dbFunc, ids, map[string]any{"balance": 42}, 1000)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3000), affected)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("balance = ?", 42).Count(&count).Error)
+		assert.Equal(t, int64(3000), count)
+	})
+
+	t.Run("Rollback discards all chunks", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			affected, err := UpdateByIDs[User](ctx, dbFunc, ids, map[string]any{"balance": 0}, 1000)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3000), affected)
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("balance = ?", 42).Count(&count).Error)
+		assert.Equal(t, int64(3000), count)
+	})
+
+	t.Run("Rejects invalid chunk size", func(t *testing.T) {
+		_, err := UpdateByIDs[User](ctx, dbFunc, ids, map[string]any{"balance": 0}, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindInBatches(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+	seedUsers(t, db, 2500)
+
+	dbFunc := GetTxOrDefault(db)
+
+	t.Run("Processes all rows in batches", func(t *testing.T) {
+		var sizes []int
+		var total int64
+		err := FindInBatches(context.Background(), dbFunc, 500, func(batch []User) error {
+			sizes = append(sizes, len(batch))
+			for _, u := range batch {
+				total += u.Balance
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int{500, 500, 500, 500, 500}, sizes)
+		assert.Equal(t, int64(2500*2501/2), total)
+	})
+
+	t.Run("Stops on first callback error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var calls int
+		err := FindInBatches(context.Background(), dbFunc, 500, func(batch []User) error {
+			calls++
+			if calls == 2 {
+				return errStop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, errStop)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Uses context transaction", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&User{Name: "in-tx", Balance: 1}).Error; err != nil {
+				return err
+			}
+			var count int
+			err := FindInBatches(ctx, dbFunc, 1000, func(batch []User) error {
+				count += len(batch)
+				return nil
+			})
+			assert.Equal(t, 2501, count)
+			return err
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Rejects non-positive batch size", func(t *testing.T) {
+		err := FindInBatches(context.Background(), dbFunc, 0, func(batch []User) error { return nil })
+		assert.Error(t, err)
+	})
+}
+
+func TestWithLockedRow(t *testing.T) {
+	// Concurrent transactions need committed data, so don't wrap the test DB in a transaction
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	user := User{Name: "Locked", Balance: 100}
+	require.NoError(t, db.Create(&user).Error)
+
+	dbFunc := GetTxOrDefault(db)
+
+	t.Run("Requires a transaction", func(t *testing.T) {
+		err := WithLockedRow(context.Background(), dbFunc, user.ID, func(u *User) error { return nil })
+		assert.ErrorIs(t, err, ErrNoTransaction)
+	})
+
+	t.Run("Concurrent callers serialize", func(t *testing.T) {
+		firstLocked := make(chan struct{})
+		seen := make(chan int64, 2)
+		errs := make(chan error, 2)
+
+		go func() {
+			errs <- RunInTx(context.Background(), db, func(ctx context.Context) error {
+				return WithLockedRow(ctx, dbFunc, user.ID, func(u *User) error {
+					seen <- u.Balance
+					close(firstLocked)
+					// Hold the lock so the second caller has to wait
+					time.Sleep(200 * time.Millisecond)
+					u.Balance += 10
+					return nil
+				})
+			})
+		}()
+
+		<-firstLocked
+		go func() {
+			errs <- RunInTx(context.Background(), db, func(ctx context.Context) error {
+				return WithLockedRow(ctx, dbFunc, user.ID, func(u *User) error {
+					seen <- u.Balance
+					u.Balance += 10
+					return nil
+				})
+			})
+		}()
+
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+		assert.Equal(t, int64(100), <-seen)
+		assert.Equal(t, int64(110), <-seen, "second caller should see the first's committed change")
+
+		var reloaded User
+		require.NoError(t, db.First(&reloaded, user.ID).Error)
+		assert.Equal(t, int64(120), reloaded.Balance)
+	})
+
+	t.Run("Callback error leaves row unchanged", func(t *testing.T) {
+		errReject := errors.New("reject")
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return WithLockedRow(ctx, dbFunc, user.ID, func(u *User) error {
+				u.Balance = 0
+				return errReject
+			})
+		})
+		assert.ErrorIs(t, err, errReject)
+
+		var reloaded User
+		require.NoError(t, db.First(&reloaded, user.ID).Error)
+		assert.Equal(t, int64(120), reloaded.Balance)
+	})
+}
+
+func TestMutate(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	counter := User{Name: "Counter"}
+	require.NoError(t, db.Create(&counter).Error)
+
+	dbFunc := GetTxOrDefault(db)
+
+	// Locking and concurrency are covered by TestWithLockedRow
+	err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+		return Mutate(ctx, dbFunc, counter.ID, func(u *User) error {
+			u.Balance++
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	var reloaded User
+	require.NoError(t, db.First(&reloaded, counter.ID).Error)
+	assert.Equal(t, int64(1), reloaded.Balance)
+}
+
+// Event has a timestamp unrelated to insertion order
+type Event struct {
+	ID         uint `gorm:"primaryKey"`
+	Name       string
+	OccurredAt time.Time
+}
+
+func TestLatestEarliest(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&Event{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	t.Run("Empty table returns ErrRecordNotFound", func(t *testing.T) {
+		_, err := Latest[Event](ctx, dbFunc, "occurred_at")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Create([]Event{
+		{Name: "middle", OccurredAt: base.Add(time.Hour)},
+		{Name: "last", OccurredAt: base.Add(2 * time.Hour)},
+		{Name: "first", OccurredAt: base},
+	}).Error)
+
+	t.Run("Latest by timestamp", func(t *testing.T) {
+		event, err := Latest[Event](ctx, dbFunc, "occurred_at")
+		require.NoError(t, err)
+		assert.Equal(t, "last", event.Name)
+	})
+
+	t.Run("Earliest by Go field name", func(t *testing.T) {
+		event, err := Earliest[Event](ctx, dbFunc, "OccurredAt")
+		require.NoError(t, err)
+		assert.Equal(t, "first", event.Name)
+	})
+
+	t.Run("Rejects unknown columns", func(t *testing.T) {
+		_, err := Latest[Event](ctx, dbFunc, "occurred_at; DROP TABLE events")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown order column")
+	})
+}
+
+var errUserNotFound = errors.New("user not found")
+
+func TestFindOrError(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	alice := User{Name: "Alice"}
+	require.NoError(t, db.Create(&alice).Error)
+
+	t.Run("Returns the row outside a transaction", func(t *testing.T) {
+		user, err := FindOrError[User](ctx, dbFunc, alice.ID, errUserNotFound)
+		require.NoError(t, err)
+		assert.Equal(t, "Alice", user.Name)
+	})
+
+	t.Run("Returns the custom error for missing rows", func(t *testing.T) {
+		user, err := FindOrError[User](ctx, dbFunc, alice.ID+1000, errUserNotFound)
+		assert.ErrorIs(t, err, errUserNotFound)
+		assert.NotErrorIs(t, err, gorm.ErrRecordNotFound)
+		assert.Nil(t, user)
+	})
+
+	t.Run("Sees rows of the context transaction", func(t *testing.T) {
+		errRollback := errors.New("rollback")
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			bob := User{Name: "Bob"}
+			require.NoError(t, dbFunc(ctx).Create(&bob).Error)
+
+			user, err := FindOrError[User](ctx, dbFunc, bob.ID, errUserNotFound)
+			require.NoError(t, err)
+			assert.Equal(t, "Bob", user.Name)
+
+			_, err = FindOrError[User](ctx, dbFunc, bob.ID+1000, errUserNotFound)
+			assert.ErrorIs(t, err, errUserNotFound)
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+	})
+
+	t.Run("Propagates other errors", func(t *testing.T) {
+		type Missing struct{ ID uint }
+		_, err := FindOrError[Missing](ctx, dbFunc, 1, errUserNotFound)
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, errUserNotFound)
+	})
+}
+
+// Note is soft-deletable
+type Note struct {
+	ID        uint `gorm:"primaryKey"`
+	Text      string
+	DeletedAt gorm.DeletedAt
+}
+
+func TestSoftDeleteRestore(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&Note{}, &Event{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	note := Note{Text: "remember"}
+	require.NoError(t, db.Create(&note).Error)
+
+	err := RunInTx(ctx, db, func(ctx context.Context) error {
+		return SoftDelete[Note](ctx, dbFunc, note.ID)
+	})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, db.First(&Note{}, note.ID).Error, gorm.ErrRecordNotFound, "soft-deleted row should be hidden")
+	var deleted Note
+	require.NoError(t, db.Unscoped().First(&deleted, note.ID).Error)
+	assert.True(t, deleted.DeletedAt.Valid)
+
+	err = RunInTx(ctx, db, func(ctx context.Context) error {
+		return Restore[Note](ctx, dbFunc, note.ID)
+	})
+	require.NoError(t, err)
+
+	var restored Note
+	require.NoError(t, db.First(&restored, note.ID).Error)
+	assert.Equal(t, "remember", restored.Text)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	t.Run("Missing rows", func(t *testing.T) {
+		assert.ErrorIs(t, SoftDelete[Note](ctx, dbFunc, 9999), gorm.ErrRecordNotFound)
+		assert.ErrorIs(t, Restore[Note](ctx, dbFunc, 9999), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("Rejects models without DeletedAt", func(t *testing.T) {
+		err := SoftDelete[Event](ctx, dbFunc, 1)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no DeletedAt field")
+	})
+}
+
+func TestList(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&Product{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	for i, name := range []string{"apple", "banana", "cherry", "apricot", "date"} {
+		require.NoError(t, db.Create(&Product{SKU: name, Name: name, Price: int64(i * 10)}).Error)
+	}
+
+	names := func(products []Product) []string {
+		var out []string
+		for _, p := range products {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	t.Run("Operators", func(t *testing.T) {
+		products, total, err := List[Product](ctx, dbFunc, []Filter{
+			{Column: "name", Op: OpLike, Value: "a%"},
+			{Column: "Price", Op: OpGt, Value: 0},
+		}, Sort{}, Page{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []string{"apricot"}, names(products))
+
+		products, total, err = List[Product](ctx, dbFunc, []Filter{
+			{Column: "sku", Op: OpIn, Value: []string{"apple", "cherry", "date"}},
+			{Column: "price", Op: OpNe, Value: 20},
+		}, Sort{Column: "price", Desc: true}, Page{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []string{"date", "apple"}, names(products))
+
+		products, _, err = List[Product](ctx, dbFunc, []Filter{
+			{Column: "price", Op: OpLt, Value: 20},
+			{Column: "name", Op: OpEq, Value: "banana"},
+		}, Sort{}, Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"banana"}, names(products))
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		var seen []string
+		for offset := 0; offset < 5; offset += 2 {
+			products, total, err := List[Product](ctx, dbFunc, nil, Sort{Column: "price"}, Page{Limit: 2, Offset: offset})
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), total, "total should ignore the page")
+			seen = append(seen, names(products)...)
+		}
+		assert.Equal(t, []string{"apple", "banana", "cherry", "apricot", "date"}, seen)
+	})
+
+	t.Run("Rejects invalid columns and operators", func(t *testing.T) {
+		_, _, err := List[Product](ctx, dbFunc, []Filter{{Column: "name; DROP TABLE products", Op: OpEq, Value: "x"}}, Sort{}, Page{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid filter")
+
+		_, _, err = List[Product](ctx, dbFunc, []Filter{{Column: "name", Op: "OR 1=1 --", Value: "x"}}, Sort{}, Page{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported operator")
+
+		_, _, err = List[Product](ctx, dbFunc, nil, Sort{Column: "secret"}, Page{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid sort")
+	})
+
+	t.Run("Participates in the context transaction", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			if err := dbFunc(ctx).Create(&Product{SKU: "uncommitted", Name: "uncommitted"}).Error; err != nil {
+				return err
+			}
+			products, total, err := List[Product](ctx, dbFunc, []Filter{{Column: "sku", Op: OpEq, Value: "uncommitted"}}, Sort{}, Page{})
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), total)
+			assert.Len(t, products, 1)
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+	})
+}
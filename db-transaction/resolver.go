@@ -0,0 +1,24 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// WithReadReplicas registers GORM's dbresolver on primary so reads outside a transaction go to replicas
+// and writes go to primary, and returns a DB function like GetTxOrDefault
+// Transactions always run on primary: statements in a context transaction use its pinned connection,
+// so reads inside RunInTx see their own writes
+func WithReadReplicas(primary *gorm.DB, replicas ...gorm.Dialector) (func(ctx context.Context) *gorm.DB, error) {
+	err := primary.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register read replicas: %w", err)
+	}
+	return GetTxOrDefault(primary), nil
+}
@@ -0,0 +1,79 @@
+package transaction
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Item is stored in both SQLite files so the row's origin shows which connection served a query
+type Item struct {
+	ID     uint `gorm:"primaryKey"`
+	Origin string
+}
+
+func TestWithReadReplicas(t *testing.T) {
+	// Two SQLite files stand in for the primary and a replica (no replication between them)
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	openSQLite := func(path string) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&Item{}))
+		return db
+	}
+	replica := openSQLite(replicaPath)
+	require.NoError(t, replica.Create(&Item{Origin: "replica"}).Error)
+
+	primary := openSQLite(primaryPath)
+	require.NoError(t, primary.Create(&Item{Origin: "primary"}).Error)
+
+	dbFunc, err := WithReadReplicas(primary, sqlite.Open(replicaPath))
+	require.NoError(t, err)
+
+	origins := func(ctx context.Context) []string {
+		var items []Item
+		require.NoError(t, dbFunc(ctx).Order("id").Find(&items).Error)
+		var result []string
+		for _, item := range items {
+			result = append(result, item.Origin)
+		}
+		return result
+	}
+	countIn := func(db *gorm.DB) int64 {
+		var n int64
+		require.NoError(t, db.Model(&Item{}).Count(&n).Error)
+		return n
+	}
+
+	t.Run("Outside transaction reads go to replica and writes to primary", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, []string{"replica"}, origins(ctx))
+
+		require.NoError(t, dbFunc(ctx).Create(&Item{Origin: "written"}).Error)
+		assert.Equal(t, int64(1), countIn(replica), "write must not reach the replica")
+		assert.Equal(t, []string{"replica"}, origins(ctx), "reads still come from the replica")
+	})
+
+	t.Run("Inside transaction reads and writes go to primary", func(t *testing.T) {
+		err := RunInTx(context.Background(), primary, func(ctx context.Context) error {
+			assert.Equal(t, []string{"primary", "written"}, origins(ctx))
+
+			if err := dbFunc(ctx).Create(&Item{Origin: "in-tx"}).Error; err != nil {
+				return err
+			}
+			assert.Equal(t, []string{"primary", "written", "in-tx"}, origins(ctx), "reads see the transaction's own writes")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), countIn(replica))
+	})
+}
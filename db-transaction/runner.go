@@ -0,0 +1,321 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// Options for RunInTx
+type txOptions struct {
+	CaptureSQL bool       // Wrap errors with the last failed statement (see SQLError)
+	Observer   TxObserver // Notified with a summary of written rows on commit
+}
+
+// TxOption configures RunInTx behavior
+type TxOption func(*txOptions)
+
+// CaptureFailedSQL wraps a failed transaction's error in an SQLError holding the statement that failed
+// Installs a gorm callback on the DB on first use; vars of statements touching secret-looking columns are redacted
+var CaptureFailedSQL TxOption = func(o *txOptions) {
+	o.CaptureSQL = true
+}
+
+// RunInTx runs fn in a transaction injected into the context
+// The transaction commits when fn returns nil and rolls back otherwise
+// If the context already holds a transaction, fn runs in a nested transaction (savepoint)
+func RunInTx(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error, opts ...TxOption) error {
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	run := func(ctx context.Context) error {
+		scope := newScope(ctx)
+		err := GetTxOrDefault(db)(ctx).Transaction(func(tx *gorm.DB) error {
+			return runInScope(ctx, tx, scope, fn)
+		})
+		if err == nil {
+			scope.committed()
+		}
+		return err
+	}
+
+	var capture *sqlCapture
+	if o.CaptureSQL {
+		if err := ensureCaptureCallbacks(db); err != nil {
+			return err
+		}
+		ctx, capture = withSQLCapture(ctx)
+	}
+
+	if TraceID(ctx) != "" {
+		if err := ensureTraceCallbacks(db); err != nil {
+			return err
+		}
+	}
+
+	var recorder *writeRecorder
+	if o.Observer != nil {
+		if err := ensureObserveCallbacks(db); err != nil {
+			return err
+		}
+		ctx, recorder = withWriteRecorder(ctx)
+	} else {
+		ctx, recorder = nestWriteRecorder(ctx)
+	}
+
+	err := run(ctx)
+	if capture != nil {
+		err = capture.wrap(err)
+	}
+	if err == nil && recorder != nil {
+		recorder.committed()
+		if o.Observer != nil {
+			o.Observer.OnCommitSummary(recorder.summary())
+		}
+	}
+	return err
+}
+
+// ErrNoTransaction is returned by helpers that must run inside a transaction
+var ErrNoTransaction = errors.New("no transaction in context")
+
+// txScopeKey is used to store the current RunInTx scope in the context
+var txScopeKey = new(int)
+
+// txScope holds hooks registered while a runner's transaction is open
+type txScope struct {
+	parent      *txScope // Enclosing runner scope when nested (savepoint)
+	preCommit   []func(tx *gorm.DB) error
+	afterCommit []func()
+}
+
+// newScope returns a scope nested in the context's runner scope, if any
+func newScope(ctx context.Context) *txScope {
+	return &txScope{parent: getScope(ctx)}
+}
+
+// runInScope runs fn with the transaction and scope in the context,
+// then runs pre-commit validators so any error rolls the transaction back
+func runInScope(ctx context.Context, tx *gorm.DB, scope *txScope, fn func(ctx context.Context) error) error {
+	ctx = context.WithValue(SetTx(ctx, tx), txScopeKey, scope)
+
+	if err := fn(ctx); err != nil {
+		return err
+	}
+
+	for _, validate := range scope.preCommit {
+		if err := validate(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// committed runs after-commit hooks once the scope's transaction committed
+// A nested scope only released its savepoint, so its hooks wait for the enclosing scope's commit
+func (s *txScope) committed() {
+	if s.parent != nil {
+		s.parent.afterCommit = append(s.parent.afterCommit, s.afterCommit...)
+		return
+	}
+	for _, hook := range s.afterCommit {
+		hook()
+	}
+}
+
+// getScope returns the innermost runner scope, or nil outside RunInTx
+func getScope(ctx context.Context) *txScope {
+	scope, _ := ctx.Value(txScopeKey).(*txScope)
+	return scope
+}
+
+// RegisterPreCommit adds a validator that the enclosing RunInTx runs just before commit
+// Validators run in registration order within the same transaction; any error triggers rollback
+// e.g. enforce invariants like "no account balance is negative"
+// Returns ErrNoTransaction when called outside RunInTx
+func RegisterPreCommit(ctx context.Context, validate func(tx *gorm.DB) error) error {
+	scope := getScope(ctx)
+	if scope == nil {
+		return ErrNoTransaction
+	}
+	scope.preCommit = append(scope.preCommit, validate)
+	return nil
+}
+
+// RegisterAfterCommit adds a hook that runs after the enclosing RunInTx commits, e.g. to publish events
+// or invalidate caches only for data that is really committed. Hooks run in registration order and are
+// dropped on rollback; hooks registered in a nested RunInTx wait for the outermost RunInTx commit
+// (a transaction from SetTx/BeginScope isn't tracked, so RunInTx inside one runs hooks on savepoint release)
+// Returns ErrNoTransaction when called outside RunInTx
+func RegisterAfterCommit(ctx context.Context, hook func()) error {
+	scope := getScope(ctx)
+	if scope == nil {
+		return ErrNoTransaction
+	}
+	scope.afterCommit = append(scope.afterCommit, hook)
+	return nil
+}
+
+// RunInTxR runs fn in a transaction like RunInTx and returns the value it produces
+// This avoids capturing outer variables; the zero value is returned when the transaction rolls back
+func RunInTxR[T any](ctx context.Context, db *gorm.DB, fn func(ctx context.Context) (T, error), opts ...TxOption) (T, error) {
+	var result T
+	err := RunInTx(ctx, db, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	}, opts...)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// Options for RunInTxWithRetry
+type retryOptions struct {
+	MaxAttempts       int                                  // Total attempts including the first
+	Backoff           time.Duration                        // Wait before each retry, multiplied by the attempt number
+	Isolation         sql.IsolationLevel                   // Isolation level used for every attempt
+	EscalateIsolation func(attempt int) sql.IsolationLevel // Per-attempt isolation, overrides Isolation
+	Stats             *TxRetryStats                        // Filled with attempt counts when set
+}
+
+// TxRetryStats reports what RunInTxWithRetry did, e.g. to assert "retried exactly twice" in tests
+type TxRetryStats struct {
+	Attempts int     // Transaction attempts made, including the first
+	Errors   []error // Errors of the failed attempts in order, the final one included when all fail
+}
+
+// RetryOption configures RunInTxWithRetry behavior
+type RetryOption func(*retryOptions)
+
+// WithMaxAttempts sets the total number of attempts (default 3)
+// n must be at least 1; RunInTxWithRetry returns an error without running fn otherwise
+func WithMaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.MaxAttempts = n
+	}
+}
+
+// WithRetryBackoff sets the base wait between attempts (default 10ms, grows linearly)
+func WithRetryBackoff(d time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.Backoff = d
+	}
+}
+
+// WithIsolation runs every attempt with the given isolation level
+func WithIsolation(level sql.IsolationLevel) RetryOption {
+	return func(o *retryOptions) {
+		o.Isolation = level
+	}
+}
+
+// EscalateIsolation chooses the isolation level per attempt (attempt starts at 1)
+// e.g. start with READ COMMITTED and escalate to SERIALIZABLE on later attempts
+func EscalateIsolation(fn func(attempt int) sql.IsolationLevel) RetryOption {
+	return func(o *retryOptions) {
+		o.EscalateIsolation = fn
+	}
+}
+
+// WithRetryStats records attempt counts into stats (reset at the start of each run)
+func WithRetryStats(stats *TxRetryStats) RetryOption {
+	return func(o *retryOptions) {
+		o.Stats = stats
+	}
+}
+
+// RunInTxWithRetry runs fn in a transaction like RunInTx, retrying the whole transaction
+// on serialization failures and deadlocks
+// If the context already holds a transaction, fn runs once in it: retries only make sense at the outermost level
+func RunInTxWithRetry(ctx context.Context, db *gorm.DB, fn func(ctx context.Context) error, opts ...RetryOption) error {
+	o := newRetryOptions(opts)
+	if GetTx(ctx) != nil {
+		if o.Stats != nil {
+			*o.Stats = TxRetryStats{Attempts: 1}
+		}
+		return RunInTx(ctx, db, fn)
+	}
+
+	return runWithRetry(ctx, o, func(ctx context.Context, txOpts *sql.TxOptions) error {
+		scope := newScope(ctx)
+		ctx, recorder := nestWriteRecorder(ctx)
+		err := GetTxOrDefault(db)(ctx).Transaction(func(tx *gorm.DB) error {
+			return runInScope(ctx, tx, scope, fn)
+		}, txOpts)
+		if err == nil {
+			scope.committed()
+			if recorder != nil {
+				recorder.committed()
+			}
+		}
+		return err
+	})
+}
+
+// newRetryOptions applies options over the defaults
+func newRetryOptions(opts []RetryOption) retryOptions {
+	o := retryOptions{
+		MaxAttempts: 3,
+		Backoff:     10 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// runWithRetry calls attempt until it succeeds, fails with a non-retryable error or attempts run out
+func runWithRetry(ctx context.Context, o retryOptions, attempt func(ctx context.Context, txOpts *sql.TxOptions) error) error {
+	if o.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1, got %d", o.MaxAttempts)
+	}
+	if o.Stats != nil {
+		*o.Stats = TxRetryStats{}
+	}
+
+	var err error
+	for i := 1; i <= o.MaxAttempts; i++ {
+		isolation := o.Isolation
+		if o.EscalateIsolation != nil {
+			isolation = o.EscalateIsolation(i)
+		}
+
+		err = attempt(ctx, &sql.TxOptions{Isolation: isolation})
+		if o.Stats != nil {
+			o.Stats.Attempts = i
+		}
+		if err != nil && o.Stats != nil {
+			o.Stats.Errors = append(o.Stats.Errors, err)
+		}
+		if err == nil || !IsRetryable(err) || i == o.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(o.Backoff * time.Duration(i)):
+		}
+	}
+	return err
+}
+
+// IsRetryable reports whether err is a Postgres serialization failure or deadlock,
+// meaning the whole transaction can safely be retried
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+	return false
+}
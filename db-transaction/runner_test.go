@@ -0,0 +1,282 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	dbtesting "db-testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestRunInTx(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	t.Run("Commits on success", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			assert.NotNil(t, GetTx(ctx))
+			return repo.CreateUser(ctx, &User{Name: "Committed"})
+		})
+		require.NoError(t, err)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("name = ?", "Committed").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Rolls back on error", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, repo.CreateUser(ctx, &User{Name: "Rolled Back"}))
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Where("name = ?", "Rolled Back").Count(&count).Error)
+		assert.Equal(t, int64(0), count)
+	})
+}
+
+func TestRunInTxR(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	t.Run("Returns value with generated ID on success", func(t *testing.T) {
+		user, err := RunInTxR(ctx, db, func(ctx context.Context) (User, error) {
+			user := User{Name: "Alice", Balance: 100}
+			err := repo.CreateUser(ctx, &user)
+			return user, err
+		})
+		require.NoError(t, err)
+		assert.NotZero(t, user.ID)
+		assert.Equal(t, "Alice", user.Name)
+	})
+
+	t.Run("Returns zero value on rollback", func(t *testing.T) {
+		user, err := RunInTxR(ctx, db, func(ctx context.Context) (User, error) {
+			user := User{Name: "Bob", Balance: 100}
+			if err := repo.CreateUser(ctx, &user); err != nil {
+				return user, err
+			}
+			return user, assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Zero(t, user)
+	})
+}
+
+// fakeTx records the isolation level requested per attempt and fails with the queued errors
+type fakeTx struct {
+	errs       []error
+	isolations []sql.IsolationLevel
+}
+
+func (f *fakeTx) attempt(ctx context.Context, txOpts *sql.TxOptions) error {
+	f.isolations = append(f.isolations, txOpts.Isolation)
+	if len(f.errs) == 0 {
+		return nil
+	}
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+var serializationFailure = &pgconn.PgError{Code: "40001", Message: "could not serialize access"}
+
+func TestRunWithRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Keeps the same isolation by default", func(t *testing.T) {
+		fake := &fakeTx{errs: []error{serializationFailure}}
+		opts := newRetryOptions([]RetryOption{WithIsolation(sql.LevelRepeatableRead), WithRetryBackoff(time.Millisecond)})
+
+		require.NoError(t, runWithRetry(ctx, opts, fake.attempt))
+		assert.Equal(t, []sql.IsolationLevel{sql.LevelRepeatableRead, sql.LevelRepeatableRead}, fake.isolations)
+	})
+
+	t.Run("Escalates isolation per attempt", func(t *testing.T) {
+		fake := &fakeTx{errs: []error{serializationFailure, serializationFailure}}
+		opts := newRetryOptions([]RetryOption{
+			WithRetryBackoff(time.Millisecond),
+			EscalateIsolation(func(attempt int) sql.IsolationLevel {
+				if attempt == 1 {
+					return sql.LevelReadCommitted
+				}
+				return sql.LevelSerializable
+			}),
+		})
+
+		require.NoError(t, runWithRetry(ctx, opts, fake.attempt))
+		assert.Equal(t, []sql.IsolationLevel{
+			sql.LevelReadCommitted,
+			sql.LevelSerializable,
+			sql.LevelSerializable,
+		}, fake.isolations)
+	})
+
+	t.Run("Does not retry other errors", func(t *testing.T) {
+		fake := &fakeTx{errs: []error{assert.AnError}}
+		err := runWithRetry(ctx, newRetryOptions(nil), fake.attempt)
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Len(t, fake.isolations, 1)
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		fake := &fakeTx{errs: []error{serializationFailure, serializationFailure, serializationFailure}}
+		var stats TxRetryStats
+		opts := newRetryOptions([]RetryOption{WithMaxAttempts(2), WithRetryBackoff(time.Millisecond), WithRetryStats(&stats)})
+
+		err := runWithRetry(ctx, opts, fake.attempt)
+		assert.True(t, IsRetryable(err))
+		assert.Len(t, fake.isolations, 2)
+		assert.Equal(t, 2, stats.Attempts)
+		assert.Len(t, stats.Errors, stats.Attempts)
+	})
+
+	t.Run("Rejects fewer than one attempt", func(t *testing.T) {
+		fake := &fakeTx{}
+		opts := newRetryOptions([]RetryOption{WithMaxAttempts(0)})
+
+		require.Error(t, runWithRetry(ctx, opts, fake.attempt))
+		assert.Empty(t, fake.isolations)
+	})
+
+	t.Run("Reports attempts in stats", func(t *testing.T) {
+		fake := &fakeTx{errs: []error{serializationFailure, serializationFailure}}
+		var stats TxRetryStats
+		opts := newRetryOptions([]RetryOption{WithRetryBackoff(time.Millisecond), WithRetryStats(&stats)})
+
+		require.NoError(t, runWithRetry(ctx, opts, fake.attempt))
+		assert.Equal(t, 3, stats.Attempts)
+		require.Len(t, stats.Errors, 2)
+		assert.True(t, IsRetryable(stats.Errors[0]))
+
+		// Stats are reset per run
+		require.NoError(t, runWithRetry(ctx, opts, (&fakeTx{}).attempt))
+		assert.Equal(t, 1, stats.Attempts)
+		assert.Empty(t, stats.Errors)
+	})
+}
+
+func TestRegisterPreCommit(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	alice := &User{Name: "Alice", Balance: 100}
+	require.NoError(t, repo.CreateUser(ctx, alice))
+
+	noNegativeBalance := func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&User{}).Where("balance < 0").Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%d accounts have a negative balance", count)
+		}
+		return nil
+	}
+
+	t.Run("Validator failure forces rollback", func(t *testing.T) {
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, RegisterPreCommit(ctx, noNegativeBalance))
+			return repo.UpdateBalance(ctx, alice.ID, -50)
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "negative balance")
+
+		found, err := repo.GetUser(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), found.Balance)
+	})
+
+	t.Run("Validators run in order before commit", func(t *testing.T) {
+		var calls []string
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, RegisterPreCommit(ctx, func(tx *gorm.DB) error {
+				calls = append(calls, "first")
+				return noNegativeBalance(tx)
+			}))
+			require.NoError(t, RegisterPreCommit(ctx, func(tx *gorm.DB) error {
+				calls = append(calls, "second")
+				return nil
+			}))
+			calls = append(calls, "fn")
+			return repo.UpdateBalance(ctx, alice.ID, 50)
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fn", "first", "second"}, calls)
+
+		found, err := repo.GetUser(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(50), found.Balance)
+	})
+
+	t.Run("Requires a RunInTx scope", func(t *testing.T) {
+		err := RegisterPreCommit(ctx, noNegativeBalance)
+		assert.ErrorIs(t, err, ErrNoTransaction)
+	})
+}
+
+func TestRegisterAfterCommit(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	t.Run("Runs in order after commit", func(t *testing.T) {
+		var calls []string
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, RegisterAfterCommit(ctx, func() { calls = append(calls, "first") }))
+			require.NoError(t, RegisterAfterCommit(ctx, func() { calls = append(calls, "second") }))
+			calls = append(calls, "fn")
+			return repo.CreateUser(ctx, &User{Name: "Alice"})
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fn", "first", "second"}, calls)
+	})
+
+	t.Run("Dropped on rollback", func(t *testing.T) {
+		called := false
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			require.NoError(t, RegisterAfterCommit(ctx, func() { called = true }))
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+		assert.False(t, called)
+	})
+
+	t.Run("Nested hooks wait for the outermost commit", func(t *testing.T) {
+		called := false
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			err := RunInTx(ctx, db, func(ctx context.Context) error {
+				return RegisterAfterCommit(ctx, func() { called = true })
+			})
+			require.NoError(t, err)
+			assert.False(t, called, "savepoint release is not a commit")
+			return nil
+		})
+		require.NoError(t, err)
+		assert.True(t, called)
+	})
+
+	t.Run("Requires a RunInTx scope", func(t *testing.T) {
+		assert.ErrorIs(t, RegisterAfterCommit(ctx, func() {}), ErrNoTransaction)
+	})
+}
@@ -0,0 +1,59 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSavepointName is returned for savepoint names that aren't plain SQL identifiers
+var ErrInvalidSavepointName = errors.New("invalid savepoint name")
+
+// savepointName matches the unquoted identifiers savepoint names are sent as; GORM doesn't quote them either
+var savepointName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// savepointTx returns the transaction in the context after checking name is safe to put in SQL
+func savepointTx(ctx context.Context, op, name string) (*gorm.DB, error) {
+	if !savepointName.MatchString(name) {
+		return nil, fmt.Errorf("%s %q: %w", op, name, ErrInvalidSavepointName)
+	}
+	tx := GetTx(ctx)
+	if tx == nil {
+		return nil, fmt.Errorf("%s %s: %w", op, name, ErrNoTransaction)
+	}
+	return tx.WithContext(ctx), nil
+}
+
+// Savepoint creates a savepoint with the given name on the transaction in the context
+// For flows that want explicit savepoints instead of nested RunInTx calls
+// Returns ErrNoTransaction if the context has no transaction, ErrInvalidSavepointName unless name is an identifier
+func Savepoint(ctx context.Context, name string) error {
+	tx, err := savepointTx(ctx, "savepoint", name)
+	if err != nil {
+		return err
+	}
+	return tx.SavePoint(name).Error
+}
+
+// RollbackTo rolls the transaction in the context back to the named savepoint
+// Writes made after the savepoint are discarded; earlier writes and the savepoint itself remain
+func RollbackTo(ctx context.Context, name string) error {
+	tx, err := savepointTx(ctx, "rollback to savepoint", name)
+	if err != nil {
+		return err
+	}
+	return tx.RollbackTo(name).Error
+}
+
+// ReleaseSavepoint releases the named savepoint, keeping its writes in the transaction
+// GORM has no equivalent, so this issues RELEASE SAVEPOINT directly
+func ReleaseSavepoint(ctx context.Context, name string) error {
+	tx, err := savepointTx(ctx, "release savepoint", name)
+	if err != nil {
+		return err
+	}
+	return tx.Exec("RELEASE SAVEPOINT " + name).Error
+}
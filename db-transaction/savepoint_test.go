@@ -0,0 +1,74 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavepoint(t *testing.T) {
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	dbFunc := GetTxOrDefault(db)
+
+	userNames := func(ctx context.Context) []string {
+		var names []string
+		require.NoError(t, dbFunc(ctx).Model(&User{}).Order("id").Pluck("name", &names).Error)
+		return names
+	}
+
+	t.Run("Rollback to savepoint discards later writes", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			require.NoError(t, dbFunc(ctx).Create(&User{Name: "Before", Balance: 1}).Error)
+			require.NoError(t, Savepoint(ctx, "sp_rollback"))
+			require.NoError(t, dbFunc(ctx).Create(&User{Name: "After", Balance: 2}).Error)
+
+			require.NoError(t, RollbackTo(ctx, "sp_rollback"))
+			assert.Equal(t, []string{"Before"}, userNames(ctx))
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Before"}, userNames(context.Background()))
+	})
+
+	t.Run("Released savepoint keeps writes", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			require.NoError(t, Savepoint(ctx, "sp_outer"))
+			require.NoError(t, Savepoint(ctx, "sp_inner"))
+			require.NoError(t, dbFunc(ctx).Create(&User{Name: "Released", Balance: 3}).Error)
+			require.NoError(t, ReleaseSavepoint(ctx, "sp_inner"))
+			assert.Equal(t, []string{"Before", "Released"}, userNames(ctx))
+
+			// Released writes belong to the enclosing savepoint
+			require.NoError(t, RollbackTo(ctx, "sp_outer"))
+			assert.Equal(t, []string{"Before"}, userNames(ctx))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Rejects names that aren't identifiers", func(t *testing.T) {
+		err := RunInTx(context.Background(), db, func(ctx context.Context) error {
+			for _, name := range []string{"", "sp; DROP TABLE users", `sp"`, "1sp"} {
+				assert.ErrorIs(t, Savepoint(ctx, name), ErrInvalidSavepointName, name)
+				assert.ErrorIs(t, RollbackTo(ctx, name), ErrInvalidSavepointName, name)
+				assert.ErrorIs(t, ReleaseSavepoint(ctx, name), ErrInvalidSavepointName, name)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"Before"}, userNames(context.Background()))
+	})
+
+	t.Run("Requires a transaction", func(t *testing.T) {
+		ctx := context.Background()
+		assert.ErrorIs(t, Savepoint(ctx, "sp"), ErrNoTransaction)
+		assert.ErrorIs(t, RollbackTo(ctx, "sp"), ErrNoTransaction)
+		assert.ErrorIs(t, ReleaseSavepoint(ctx, "sp"), ErrNoTransaction)
+	})
+}
@@ -0,0 +1,72 @@
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GooseVersionTable is the default goose migration version table (see sql-migration pattern)
+const GooseVersionTable = "goose_db_version"
+
+// schemaOptions configure SchemaVersion and RequireMinVersion
+type schemaOptions struct {
+	VersionTable string // Goose version table, optionally schema-qualified
+}
+
+// SchemaOption configures SchemaVersion and RequireMinVersion
+type SchemaOption func(*schemaOptions)
+
+// WithVersionTable reads the version from table instead of GooseVersionTable
+// The name may be schema-qualified (e.g. "billing.goose_db_version") to match a migrator with Config.Schema
+func WithVersionTable(table string) SchemaOption {
+	return func(o *schemaOptions) {
+		o.VersionTable = table
+	}
+}
+
+// ErrSchemaBehind is returned when the database migration version is older than required
+var ErrSchemaBehind = errors.New("database schema is behind required migration version")
+
+// SchemaVersion returns the current goose migration version, using the context transaction if present
+// Returns 0 when the version table doesn't exist yet
+func SchemaVersion(ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, opts ...SchemaOption) (int64, error) {
+	o := schemaOptions{VersionTable: GooseVersionTable}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	db := dbFunc(ctx)
+
+	// Check existence first: a failed query would abort the surrounding transaction
+	var exists bool
+	if err := db.Raw("SELECT to_regclass(?) IS NOT NULL", o.VersionTable).Row().Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check migration version table: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	// clause.Table quotes each part of a schema-qualified name
+	err := db.Raw("SELECT COALESCE(MAX(version_id), 0) FROM ? WHERE is_applied", clause.Table{Name: o.VersionTable}).Row().Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, nil
+}
+
+// RequireMinVersion returns ErrSchemaBehind if the database hasn't reached minVersion
+// Lets handlers degrade gracefully during rolling migrations (e.g. skip a column that doesn't exist yet)
+func RequireMinVersion(ctx context.Context, dbFunc func(ctx context.Context) *gorm.DB, minVersion int64, opts ...SchemaOption) error {
+	version, err := SchemaVersion(ctx, dbFunc, opts...)
+	if err != nil {
+		return err
+	}
+	if version < minVersion {
+		return fmt.Errorf("%w: at version %d, requires %d", ErrSchemaBehind, version, minVersion)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package transaction
+
+import (
+	"context"
+	"testing"
+
+	dbtesting "db-testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// partialMigrationHook simulates goose having applied only migration 1 of 2
+func partialMigrationHook(db *gorm.DB) error {
+	return db.Exec(`
+		CREATE TABLE goose_db_version (
+			id SERIAL PRIMARY KEY,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP DEFAULT NOW()
+		);
+		INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, true), (1, true);
+	`).Error
+}
+
+func TestRequireMinVersion(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Partially migrated database", func(t *testing.T) {
+		db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBWithHook(partialMigrationHook))
+		dbFunc := GetTxOrDefault(db)
+
+		version, err := SchemaVersion(ctx, dbFunc)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), version)
+
+		assert.NoError(t, RequireMinVersion(ctx, dbFunc, 1))
+
+		err = RequireMinVersion(ctx, dbFunc, 2)
+		require.ErrorIs(t, err, ErrSchemaBehind)
+	})
+
+	t.Run("Checks through the context transaction", func(t *testing.T) {
+		db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBWithHook(partialMigrationHook))
+		dbFunc := GetTxOrDefault(db)
+
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			// Migration 2 applied inside the transaction is visible to the gate
+			if err := dbFunc(ctx).Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (2, true)").Error; err != nil {
+				return err
+			}
+			return RequireMinVersion(ctx, dbFunc, 2)
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("Reads a schema-qualified version table", func(t *testing.T) {
+		db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBWithHook(func(db *gorm.DB) error {
+			return db.Exec(`
+				CREATE SCHEMA billing;
+				CREATE TABLE billing.goose_db_version (
+					id SERIAL PRIMARY KEY,
+					version_id BIGINT NOT NULL,
+					is_applied BOOLEAN NOT NULL,
+					tstamp TIMESTAMP DEFAULT NOW()
+				);
+				INSERT INTO billing.goose_db_version (version_id, is_applied) VALUES (0, true), (1, true), (2, true);
+			`).Error
+		}))
+		dbFunc := GetTxOrDefault(db)
+
+		version, err := SchemaVersion(ctx, dbFunc, WithVersionTable("billing.goose_db_version"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), version)
+		assert.NoError(t, RequireMinVersion(ctx, dbFunc, 2, WithVersionTable("billing.goose_db_version")))
+
+		// The default table isn't there
+		version, err = SchemaVersion(ctx, dbFunc)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), version)
+	})
+
+	t.Run("Missing version table counts as version 0", func(t *testing.T) {
+		db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff)
+		dbFunc := GetTxOrDefault(db)
+
+		version, err := SchemaVersion(ctx, dbFunc)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), version)
+
+		require.ErrorIs(t, RequireMinVersion(ctx, dbFunc, 1), ErrSchemaBehind)
+	})
+}
@@ -0,0 +1,100 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scope is a manually managed transaction carried in a context
+// Prefer RunInTx; use a scope when commit and rollback happen in different places (e.g. test setup/teardown)
+type Scope struct {
+	id   uint64
+	tx   *gorm.DB
+	once sync.Once
+}
+
+// TxInfo describes a scope that is still open
+type TxInfo struct {
+	ID      uint64
+	Started time.Time
+	Stack   string // Stack trace of the BeginScope call
+}
+
+// Registry of open scopes for leak detection
+var (
+	activeScopes   = map[uint64]TxInfo{}
+	nextScopeID    uint64
+	activeScopesMu sync.Mutex
+)
+
+// BeginScope begins a transaction on db and returns a context carrying it
+// Every scope must end with Commit or Rollback; until then it is listed by ActiveTransactions
+func BeginScope(ctx context.Context, db *gorm.DB) (context.Context, *Scope, error) {
+	tx := GetTxOrDefault(db)(ctx).Begin()
+	if tx.Error != nil {
+		return ctx, nil, fmt.Errorf("failed to begin scope: %w", tx.Error)
+	}
+
+	activeScopesMu.Lock()
+	nextScopeID++
+	scope := &Scope{id: nextScopeID, tx: tx}
+	activeScopes[scope.id] = TxInfo{ID: scope.id, Started: time.Now(), Stack: string(debug.Stack())}
+	activeScopesMu.Unlock()
+
+	return SetTx(ctx, tx), scope, nil
+}
+
+// Commit commits the scope's transaction
+func (s *Scope) Commit() error {
+	s.end()
+	return s.tx.Commit().Error
+}
+
+// Rollback rolls the scope's transaction back
+// Can be deferred as a safety net: after Commit it only returns sql.ErrTxDone
+func (s *Scope) Rollback() error {
+	s.end()
+	return s.tx.Rollback().Error
+}
+
+// end removes the scope from the registry
+func (s *Scope) end() {
+	s.once.Do(func() {
+		activeScopesMu.Lock()
+		delete(activeScopes, s.id)
+		activeScopesMu.Unlock()
+	})
+}
+
+// ActiveTransactions returns the scopes begun with BeginScope that were neither committed nor rolled back
+// The registry is process-wide, so parallel tests see each other's scopes
+func ActiveTransactions() []TxInfo {
+	activeScopesMu.Lock()
+	defer activeScopesMu.Unlock()
+
+	infos := make([]TxInfo, 0, len(activeScopes))
+	for _, info := range activeScopes {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// AssertNoLeakedTransactions fails the test for each open scope, printing where it was begun
+// Register it with t.Cleanup(func() { AssertNoLeakedTransactions(t) }) or call it at the end of a test
+func AssertNoLeakedTransactions(t testing.TB) {
+	t.Helper()
+
+	for _, info := range ActiveTransactions() {
+		t.Errorf("Transaction scope %d begun %s ago was never committed or rolled back:\n%s",
+			info.ID, time.Since(info.Started).Round(time.Millisecond), strings.TrimSpace(info.Stack))
+	}
+}
@@ -0,0 +1,61 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// errorfT records Errorf instead of failing the test
+type errorfT struct {
+	*testing.T
+	errors []string
+}
+
+func (e *errorfT) Errorf(format string, args ...any) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+func TestBeginScope(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "scope.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	t.Run("Commit closes the scope", func(t *testing.T) {
+		ctx, scope, err := BeginScope(context.Background(), db)
+		require.NoError(t, err)
+		require.NoError(t, GetTxOrDefault(db)(ctx).Create(&User{Name: "Alice"}).Error)
+		require.NoError(t, scope.Commit())
+
+		AssertNoLeakedTransactions(t)
+
+		var count int64
+		require.NoError(t, db.Model(&User{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Leaked scope is reported with its stack", func(t *testing.T) {
+		_, scope, err := BeginScope(context.Background(), db)
+		require.NoError(t, err)
+
+		active := ActiveTransactions()
+		require.Len(t, active, 1)
+		assert.Contains(t, active[0].Stack, "TestBeginScope")
+
+		ft := &errorfT{T: t}
+		AssertNoLeakedTransactions(ft)
+		require.Len(t, ft.errors, 1)
+		assert.Contains(t, ft.errors[0], "never committed or rolled back")
+		assert.Contains(t, ft.errors[0], "scope_test.go")
+
+		require.NoError(t, scope.Rollback())
+		assert.Empty(t, ActiveTransactions())
+	})
+}
@@ -0,0 +1,114 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// traceIDKey is used to store the trace ID in the context
+var traceIDKey = new(int)
+
+// WithTraceID tags transactions run by RunInTx with ctx with a trace/correlation ID
+// Every statement in the transaction gets a trailing /* trace:id */ comment, so slow queries in the
+// Postgres logs (log_min_duration_statement, pg_stat_activity) can be matched to application traces.
+// Statements outside a transaction are not tagged.
+// The comment makes the SQL text unique per trace, so with pgx's default statement cache every tagged
+// statement is prepared and cached anew. Connect with default_query_exec_mode=exec in the DSN (or
+// postgres.Config{PreferSimpleProtocol: true}) when using trace IDs
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey, id)
+}
+
+// TraceID returns the trace ID set by WithTraceID, or an empty string
+func TraceID(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey).(string)
+	return id
+}
+
+// traceCommentClause is the clause name of the trace comment appended to built statements
+const traceCommentClause = "TRANSACTION_TRACE_COMMENT"
+
+// traceComment renders the trace comment; it implements clause.Interface
+type traceComment struct {
+	id string
+}
+
+func (c traceComment) Name() string {
+	return traceCommentClause
+}
+
+func (c traceComment) Build(builder clause.Builder) {
+	builder.WriteString(c.String())
+}
+
+func (c traceComment) MergeClause(cl *clause.Clause) {
+	cl.Name = "" // Don't write the clause name before the comment
+	cl.Expression = c
+}
+
+// String returns the comment with a leading space; comment delimiters in the ID are defused
+func (c traceComment) String() string {
+	id := strings.NewReplacer("/*", "/ *", "*/", "* /").Replace(c.id)
+	return " /* trace:" + id + " */"
+}
+
+// traceCallbackName is the gorm callback appending trace comments
+const traceCallbackName = "transaction:trace_comment"
+
+// traceCallbacksMu serializes callback registration across runners
+var traceCallbacksMu sync.Mutex
+
+// ensureTraceCallbacks registers the trace comment callbacks on db once
+func ensureTraceCallbacks(db *gorm.DB) error {
+	traceCallbacksMu.Lock()
+	defer traceCallbacksMu.Unlock()
+
+	cb := db.Callback()
+	if cb.Create().Get(traceCallbackName) != nil {
+		return nil
+	}
+
+	for _, register := range []func() error{
+		func() error { return cb.Create().Before("gorm:create").Register(traceCallbackName, addTraceComment) },
+		func() error { return cb.Query().Before("gorm:query").Register(traceCallbackName, addTraceComment) },
+		func() error { return cb.Update().Before("gorm:update").Register(traceCallbackName, addTraceComment) },
+		func() error { return cb.Delete().Before("gorm:delete").Register(traceCallbackName, addTraceComment) },
+		func() error { return cb.Row().Before("gorm:row").Register(traceCallbackName, addTraceComment) },
+		func() error { return cb.Raw().Before("gorm:raw").Register(traceCallbackName, addTraceComment) },
+	} {
+		if err := register(); err != nil {
+			return fmt.Errorf("failed to register trace callback: %w", err)
+		}
+	}
+	return nil
+}
+
+// addTraceComment appends the context's trace comment to statements run in a transaction
+// Raw statements already hold their SQL; others get a clause built after the regular ones
+func addTraceComment(db *gorm.DB) {
+	ctx := db.Statement.Context
+	if db.Error != nil || ctx == nil || GetTx(ctx) == nil {
+		return
+	}
+	id := TraceID(ctx)
+	if id == "" {
+		return
+	}
+
+	comment := traceComment{id: id}
+	if db.Statement.SQL.Len() > 0 {
+		db.Statement.SQL.WriteString(comment.String())
+		return
+	}
+	db.Statement.AddClause(comment)
+	if !slices.Contains(db.Statement.BuildClauses, traceCommentClause) {
+		// BuildClauses may be shared with the processor, so never append in place
+		db.Statement.BuildClauses = append(slices.Clone(db.Statement.BuildClauses), traceCommentClause)
+	}
+}
@@ -0,0 +1,111 @@
+package transaction
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlLog records the SQL of every executed statement
+type sqlLog struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (l *sqlLog) record(db *gorm.DB) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.statements = append(l.statements, db.Statement.SQL.String())
+}
+
+// take returns the statements recorded since the last call
+func (l *sqlLog) take() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	statements := l.statements
+	l.statements = nil
+	return statements
+}
+
+func TestWithTraceID(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "trace.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&User{}))
+
+	log := &sqlLog{}
+	cb := db.Callback()
+	require.NoError(t, cb.Create().After("*").Register("test:log", log.record))
+	require.NoError(t, cb.Query().After("*").Register("test:log", log.record))
+	require.NoError(t, cb.Update().After("*").Register("test:log", log.record))
+	require.NoError(t, cb.Delete().After("*").Register("test:log", log.record))
+	require.NoError(t, cb.Row().After("*").Register("test:log", log.record))
+	require.NoError(t, cb.Raw().After("*").Register("test:log", log.record))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := WithTraceID(context.Background(), "req-123")
+	assert.Equal(t, "req-123", TraceID(ctx))
+
+	t.Run("Statements in the transaction carry the trace comment", func(t *testing.T) {
+		log.take()
+		err := RunInTx(ctx, db, func(ctx context.Context) error {
+			user := User{Name: "Alice"}
+			if err := dbFunc(ctx).Create(&user).Error; err != nil {
+				return err
+			}
+			if err := dbFunc(ctx).First(&User{}, user.ID).Error; err != nil {
+				return err
+			}
+			if err := dbFunc(ctx).Model(&user).Update("balance", 10).Error; err != nil {
+				return err
+			}
+			var count int64
+			if err := dbFunc(ctx).Raw("SELECT count(*) FROM users").Scan(&count).Error; err != nil {
+				return err
+			}
+			if err := dbFunc(ctx).Exec("UPDATE users SET balance = balance + 1").Error; err != nil {
+				return err
+			}
+			return dbFunc(ctx).Delete(&user).Error
+		})
+		require.NoError(t, err)
+
+		statements := log.take()
+		require.Len(t, statements, 6)
+		for _, sql := range statements {
+			assert.True(t, strings.HasSuffix(sql, " /* trace:req-123 */"), "missing trace comment: %s", sql)
+		}
+	})
+
+	t.Run("Statements outside a transaction are not tagged", func(t *testing.T) {
+		log.take()
+		require.NoError(t, dbFunc(ctx).Create(&User{Name: "Bob"}).Error)
+		require.NoError(t, dbFunc(context.Background()).First(&User{}).Error)
+		require.NoError(t, RunInTx(context.Background(), db, func(ctx context.Context) error {
+			return dbFunc(ctx).First(&User{}).Error
+		}))
+
+		for _, sql := range log.take() {
+			assert.NotContains(t, sql, "trace:")
+		}
+	})
+
+	t.Run("Comment delimiters in the ID are defused", func(t *testing.T) {
+		log.take()
+		ctx := WithTraceID(context.Background(), "x */ DROP TABLE users; /*")
+		require.NoError(t, RunInTx(ctx, db, func(ctx context.Context) error {
+			return dbFunc(ctx).First(&User{}).Error
+		}))
+
+		statements := log.take()
+		require.Len(t, statements, 1)
+		assert.Equal(t, 1, strings.Count(statements[0], "*/"), "only the closing delimiter: %s", statements[0])
+	})
+}
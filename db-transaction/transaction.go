@@ -20,13 +20,16 @@ func Fix(db *gorm.DB) func(ctx context.Context) *gorm.DB {
 }
 
 // GetTxOrDefault creates a database function that uses a transaction if available in context,
-// otherwise falls back to the provided default database
+// then a connection pinned by WithPinnedConn, otherwise falls back to the provided default database
 // This is the most common pattern for repositories
 func GetTxOrDefault(defaultDB *gorm.DB) func(ctx context.Context) *gorm.DB {
 	return func(ctx context.Context) *gorm.DB {
 		if tx := GetTx(ctx); tx != nil {
 			return tx.WithContext(ctx)
 		}
+		if conn := getPinnedConn(ctx); conn != nil {
+			return conn.WithContext(ctx)
+		}
 		return defaultDB.WithContext(ctx)
 	}
 }
@@ -48,6 +51,12 @@ func SelectForUpdate(ctx context.Context) context.Context {
 	return context.WithValue(ctx, selectForUpdateKey, true)
 }
 
+// WithoutSelectForUpdate creates a context with SELECT FOR UPDATE disabled
+// Use it for nested reads that shouldn't take the lock; the parent context keeps its setting
+func WithoutSelectForUpdate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, selectForUpdateKey, false)
+}
+
 // GetTx retrieves the transaction from the context
 // Returns nil if no transaction is set
 func GetTx(ctx context.Context) *gorm.DB {
@@ -69,6 +78,16 @@ func SetTx(ctx context.Context, tx *gorm.DB) context.Context {
 	return context.WithValue(ctx, ctxKey, tx)
 }
 
+// WithoutTx creates a context with the transaction cleared
+// Repositories using GetTxOrDefault will use the default DB within this scope,
+// e.g. for audit writes that must commit even if the surrounding transaction rolls back.
+// A pinned connection is cleared too, since the transaction may have been begun on it.
+// The parent context is not affected
+func WithoutTx(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, pinnedConnKey, (*gorm.DB)(nil))
+	return context.WithValue(ctx, ctxKey, (*gorm.DB)(nil))
+}
+
 // SetTxFunc stores a transaction function in the context
 // Alternative approach that stores a function instead of the transaction directly
 func SetTxFunc(ctx context.Context, txFunc func(ctx context.Context) *gorm.DB) context.Context {
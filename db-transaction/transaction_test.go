@@ -91,6 +91,26 @@ func TestTransactionContext(t *testing.T) {
 
 		tx.Rollback()
 	})
+
+	t.Run("WithoutSelectForUpdate clears the flag in a nested scope", func(t *testing.T) {
+		tx := db.Begin()
+		defer tx.Rollback()
+
+		lockedCtx := SelectForUpdate(SetTx(context.Background(), tx))
+		unlockedCtx := WithoutSelectForUpdate(lockedCtx)
+		assert.True(t, IsSelectForUpdate(lockedCtx))
+		assert.False(t, IsSelectForUpdate(unlockedCtx))
+
+		selectSQL := func(ctx context.Context) string {
+			var users []User
+			return GetTx(ctx).Session(&gorm.Session{DryRun: true}).Find(&users).Statement.SQL.String()
+		}
+		assert.Contains(t, selectSQL(lockedCtx), "FOR UPDATE")
+		assert.NotContains(t, selectSQL(unlockedCtx), "FOR UPDATE")
+
+		// Parent context still locks
+		assert.Contains(t, selectSQL(lockedCtx), "FOR UPDATE")
+	})
 }
 
 // Example usage in a repository
@@ -110,6 +130,11 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 	}
 }
 
+// DBFunc returns the db function the repository queries through
+func (r UserRepository) DBFunc() func(ctx context.Context) *gorm.DB {
+	return r.db
+}
+
 func (r *UserRepository) CreateUser(ctx context.Context, user *User) error {
 	return r.db(ctx).Create(user).Error
 }
@@ -228,3 +253,45 @@ func TestRepositoryWithTransaction(t *testing.T) {
 		assert.Equal(t, int64(800), finalUser2.Balance)
 	})
 }
+
+// AuditLog is used to verify writes that bypass the surrounding transaction
+type AuditLog struct {
+	ID     uint `gorm:"primaryKey"`
+	Action string
+}
+
+func TestWithoutTx(t *testing.T) {
+	// No transaction wrapping - the audit row must be committed for real
+	db := dbtesting.CreateTestDB(t, dbtesting.EnvTest, dbtesting.DBDebugOff, dbtesting.DBNoWrapInTransaction)
+	require.NoError(t, db.AutoMigrate(&User{}, &AuditLog{}))
+
+	dbFunc := GetTxOrDefault(db)
+	ctx := context.Background()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txCtx := SetTx(ctx, tx)
+
+		if err := dbFunc(txCtx).Create(&User{Name: "Rolled Back", Balance: 100}).Error; err != nil {
+			return err
+		}
+
+		// Audit write bypasses the transaction and commits independently
+		auditCtx := WithoutTx(txCtx)
+		assert.Nil(t, GetTx(auditCtx))
+		if err := dbFunc(auditCtx).Create(&AuditLog{Action: "transfer attempted"}).Error; err != nil {
+			return err
+		}
+
+		// Parent context still carries the transaction
+		assert.NotNil(t, GetTx(txCtx))
+
+		return assert.AnError // force rollback
+	})
+	require.ErrorIs(t, err, assert.AnError)
+
+	var userCount, auditCount int64
+	require.NoError(t, db.Model(&User{}).Count(&userCount).Error)
+	require.NoError(t, db.Model(&AuditLog{}).Count(&auditCount).Error)
+	assert.Equal(t, int64(0), userCount, "main transaction should be rolled back")
+	assert.Equal(t, int64(1), auditCount, "audit row should survive the rollback")
+}
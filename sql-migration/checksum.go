@@ -0,0 +1,208 @@
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+// ChecksumTable stores a hash of each applied migration (goose doesn't track checksums)
+// Only created by migrators with checksum tracking on, next to the goose version table
+const ChecksumTable = "goose_migration_checksums"
+
+// ErrChecksumsNotTracked is returned by VerifyChecksums when the migrator doesn't track checksums
+var ErrChecksumsNotTracked = errors.New("migration checksums are not tracked; set Config.TrackChecksums")
+
+// ChecksumMismatch describes an applied migration whose file changed after it was applied
+type ChecksumMismatch struct {
+	Version  int64
+	File     string
+	Expected string // Checksum recorded when the migration was applied
+	Actual   string // Checksum of the current file (empty if the file is missing)
+}
+
+// migrationFile is a migration source file with its parsed version
+type migrationFile struct {
+	Version int64
+	Path    string
+}
+
+// VerifyChecksums compares applied migrations against the current migration files
+// Returns one mismatch per migration edited (or removed) after being applied, or ErrChecksumsNotTracked
+func (m *Migrator) VerifyChecksums(ctx context.Context) ([]ChecksumMismatch, error) {
+	if !m.checksums {
+		return nil, ErrChecksumsNotTracked
+	}
+	table, err := m.ensureChecksumTable(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, filename, checksum FROM "+table+" ORDER BY version_id")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read migration checksums")
+	}
+	defer rows.Close()
+
+	var mismatches []ChecksumMismatch
+	for rows.Next() {
+		var version int64
+		var file, expected string
+		if err := rows.Scan(&version, &file, &expected); err != nil {
+			return nil, errors.Wrap(err, "failed to scan migration checksum")
+		}
+
+		actual, err := m.fileChecksum(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if actual != expected {
+			mismatches = append(mismatches, ChecksumMismatch{
+				Version:  version,
+				File:     file,
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+
+	return mismatches, rows.Err()
+}
+
+// recordChecksums stores checksums for applied migrations that don't have one yet, when tracking them
+// Go migrations registered without a file have nothing to hash and get no checksum
+func (m *Migrator) recordChecksums(ctx context.Context) error {
+	if !m.checksums {
+		return nil
+	}
+	version, err := m.gooseVersion(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get database version")
+	}
+	return m.insertChecksums(ctx, m.db, version)
+}
+
+// insertChecksums stores checksums for migrations up to version through db (the pool or a transaction),
+// when tracking them
+func (m *Migrator) insertChecksums(ctx context.Context, db execer, version int64) error {
+	if !m.checksums {
+		return nil
+	}
+	table, err := m.ensureChecksumTable(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	files, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.Version > version {
+			continue
+		}
+		checksum, err := m.fileChecksum(f.Path)
+		if err != nil {
+			return err
+		}
+		_, err = db.ExecContext(ctx,
+			"INSERT INTO "+table+" (version_id, filename, checksum) VALUES ($1, $2, $3) ON CONFLICT (version_id) DO NOTHING",
+			f.Version, f.Path, checksum)
+		if err != nil {
+			return errors.Wrapf(err, "failed to record checksum for %s", f.Path)
+		}
+	}
+
+	return nil
+}
+
+// forgetChecksums removes checksums of migrations that are no longer applied, when tracking them
+func (m *Migrator) forgetChecksums(ctx context.Context) error {
+	if !m.checksums {
+		return nil
+	}
+	table, err := m.ensureChecksumTable(ctx, m.db)
+	if err != nil {
+		return err
+	}
+
+	version, err := m.gooseVersion(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get database version")
+	}
+
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM "+table+" WHERE version_id > $1", version); err != nil {
+		return errors.Wrap(err, "failed to remove migration checksums")
+	}
+	return nil
+}
+
+// execer runs statements on a *sql.DB or *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ensureChecksumTable creates the checksum companion table if missing and returns its quoted name,
+// schema-qualified like the version table
+func (m *Migrator) ensureChecksumTable(ctx context.Context, db execer) (string, error) {
+	table, err := m.qualifiedTable(ChecksumTable)
+	if err != nil {
+		return "", err
+	}
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		version_id BIGINT PRIMARY KEY,
+		filename TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return table, errors.Wrap(err, "failed to create migration checksum table")
+}
+
+// migrationFiles lists migration files with their versions, sorted by version
+// For a provider these are its own sources that have a file (SQL or Go); Go migrations without one are left out
+func (m *Migrator) migrationFiles() ([]migrationFile, error) {
+	if m.provider != nil {
+		var files []migrationFile
+		for _, s := range m.providerSources() {
+			if s.Path != "" {
+				files = append(files, migrationFile{Version: s.Version, Path: path.Join("migrations", s.Path)})
+			}
+		}
+		return files, nil
+	}
+
+	paths, err := fs.Glob(m.fsys, path.Join("migrations", "*.sql"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list migration files")
+	}
+
+	files := make([]migrationFile, 0, len(paths))
+	for _, p := range paths {
+		version, err := goose.NumericComponent(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse migration version from %s", p)
+		}
+		files = append(files, migrationFile{Version: version, Path: p})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Version < files[j].Version })
+	return files, nil
+}
+
+// fileChecksum returns the hex-encoded SHA-256 of a migration file
+func (m *Migrator) fileChecksum(file string) (string, error) {
+	body, err := fs.ReadFile(m.fsys, file)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read migration file %s", file)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
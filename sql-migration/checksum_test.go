@@ -0,0 +1,73 @@
+package migration
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// copyFS copies an fs.FS into an in-memory MapFS so files can be modified
+func copyFS(t *testing.T, fsys fs.FS) fstest.MapFS {
+	t.Helper()
+	mapFS := fstest.MapFS{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		mapFS[path] = &fstest.MapFile{Data: body}
+		return nil
+	})
+	require.NoError(t, err)
+	return mapFS
+}
+
+func TestVerifyChecksumsNotTracked(t *testing.T) {
+	migrator := NewMigratorWithFS(nil, migrationFS)
+	_, err := migrator.VerifyChecksums(context.Background())
+	assert.ErrorIs(t, err, ErrChecksumsNotTracked)
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	config := testConfig()
+	config.TrackChecksums = true
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	require.NoError(t, migrator.Up(ctx))
+	t.Cleanup(func() {
+		migrator.fsys = migrationFS
+		assert.NoError(t, migrator.Down(ctx))
+		assert.NoError(t, migrator.Down(ctx))
+	})
+
+	t.Run("No mismatches for untouched migrations", func(t *testing.T) {
+		mismatches, err := migrator.VerifyChecksums(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, mismatches)
+	})
+
+	t.Run("Reports tampered migration", func(t *testing.T) {
+		tampered := copyFS(t, migrationFS)
+		file := tampered["migrations/002_create_orders.sql"]
+		file.Data = append(file.Data, []byte("\n-- edited after apply\n")...)
+		migrator.fsys = tampered
+		defer func() { migrator.fsys = migrationFS }()
+
+		mismatches, err := migrator.VerifyChecksums(ctx)
+		require.NoError(t, err)
+		require.Len(t, mismatches, 1)
+		assert.Equal(t, int64(2), mismatches[0].Version)
+		assert.Equal(t, "migrations/002_create_orders.sql", mismatches[0].File)
+		assert.NotEqual(t, mismatches[0].Expected, mismatches[0].Actual)
+	})
+}
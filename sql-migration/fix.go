@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+// RenamedMigration is a migration file renamed by FixVersions
+type RenamedMigration struct {
+	From string // Old file name
+	To   string // New file name
+}
+
+// FixVersions renames timestamp-versioned migrations in dir to sequential versions, like `goose fix`
+// Timestamped files are numbered in timestamp order after the highest sequential version (00001_, 00002_, ...)
+// Use it when releasing migrations developed with timestamps; returns the renames performed
+func FixVersions(dir string) ([]RenamedMigration, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list migration files")
+	}
+
+	type versionedPath struct {
+		version int64
+		path    string
+	}
+	var timestamped []versionedPath
+	next := int64(1)
+	for _, p := range paths {
+		version, err := goose.NumericComponent(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse migration version from %s", p)
+		}
+		if _, err := time.Parse(gooseTimestampFormat, strconv.FormatInt(version, 10)); err == nil {
+			timestamped = append(timestamped, versionedPath{version: version, path: p})
+			continue
+		}
+		next = max(next, version+1)
+	}
+	sort.Slice(timestamped, func(i, j int) bool { return timestamped[i].version < timestamped[j].version })
+
+	renamed := make([]RenamedMigration, 0, len(timestamped))
+	for _, m := range timestamped {
+		from := filepath.Base(m.path)
+		to := strings.Replace(from, strconv.FormatInt(m.version, 10), sequentialVersion(next), 1)
+		if _, err := os.Stat(filepath.Join(dir, to)); err == nil {
+			return renamed, errors.Errorf("can't rename %s: %s already exists", from, to)
+		}
+		if err := os.Rename(m.path, filepath.Join(dir, to)); err != nil {
+			return renamed, errors.Wrapf(err, "failed to rename %s", from)
+		}
+		renamed = append(renamed, RenamedMigration{From: from, To: to})
+		next++
+	}
+	return renamed, nil
+}
+
+// sequentialVersion formats a version the way goose names sequential migrations
+func sequentialVersion(version int64) string {
+	return fmt.Sprintf("%05d", version)
+}
@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixVersions(t *testing.T) {
+	writeMigrations := func(t *testing.T, names ...string) string {
+		dir := t.TempDir()
+		for _, name := range names {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("-- +goose Up\nSELECT 1;\n"), 0o644))
+		}
+		return dir
+	}
+	listDir := func(t *testing.T, dir string) []string {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		return names
+	}
+
+	t.Run("Renames timestamps to sequential versions in order", func(t *testing.T) {
+		dir := writeMigrations(t, "20240315120000_add_orders.sql", "20240101090000_create_users.sql")
+
+		renamed, err := FixVersions(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []RenamedMigration{
+			{From: "20240101090000_create_users.sql", To: "00001_create_users.sql"},
+			{From: "20240315120000_add_orders.sql", To: "00002_add_orders.sql"},
+		}, renamed)
+		assert.Equal(t, []string{"00001_create_users.sql", "00002_add_orders.sql"}, listDir(t, dir))
+	})
+
+	t.Run("Continues after existing sequential versions", func(t *testing.T) {
+		dir := writeMigrations(t, "00001_create_users.sql", "00002_add_email.sql", "20240315120000_add_orders.sql")
+
+		renamed, err := FixVersions(dir)
+		require.NoError(t, err)
+		assert.Equal(t, []RenamedMigration{{From: "20240315120000_add_orders.sql", To: "00003_add_orders.sql"}}, renamed)
+	})
+
+	t.Run("No timestamped files is a no-op", func(t *testing.T) {
+		dir := writeMigrations(t, "00001_create_users.sql")
+
+		renamed, err := FixVersions(dir)
+		require.NoError(t, err)
+		assert.Empty(t, renamed)
+		assert.Equal(t, []string{"00001_create_users.sql"}, listDir(t, dir))
+	})
+}
@@ -0,0 +1,121 @@
+// Package migrationtest provides test helpers for the migration package
+// Kept separate so binaries linking the migrator don't pull in testing and testify
+package migrationtest
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"testing"
+
+	migration "sql-migration"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// WithMigrations runs all migrations for the test and resets them (rolls everything back) on cleanup
+// The returned teardown can be called to reset earlier; it runs at most once
+func WithMigrations(t testing.TB, config migration.Config) func() {
+	t.Helper()
+
+	migrator, err := migration.NewMigrator(config)
+	require.NoError(t, err, "failed to create migrator")
+
+	require.NoError(t, migrator.Up(context.Background()), "failed to run migrations")
+
+	var once sync.Once
+	teardown := func() {
+		once.Do(func() {
+			if err := migrator.Reset(context.Background()); err != nil {
+				t.Errorf("failed to reset migrations: %v", err)
+			}
+			migrator.Close()
+		})
+	}
+	t.Cleanup(teardown)
+
+	return teardown
+}
+
+// TestEachMigrationReversible applies every pending migration's Up then Down and checks the schema
+// returns to its prior state, catching Down scripts that don't reverse their Up
+// Each migration is re-applied before moving on; the database is rolled back to its starting version afterwards
+func TestEachMigrationReversible(t *testing.T, config migration.Config) {
+	t.Helper()
+
+	migrator, err := migration.NewMigrator(config)
+	require.NoError(t, err, "failed to create migrator")
+	defer migrator.Close()
+
+	db, err := sql.Open("postgres", config.ConnString())
+	require.NoError(t, err, "failed to open database")
+	defer db.Close()
+
+	ctx := context.Background()
+	startVersion, err := migrator.Version(ctx)
+	require.NoError(t, err)
+
+	pending, err := migrator.PendingMigrations(ctx)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, migrator.DownTo(ctx, startVersion), "failed to restore starting version")
+	}()
+
+	for _, m := range pending {
+		name := m.Name
+		if name == "" {
+			name = "go migration " + strconv.FormatInt(m.Version, 10)
+		}
+
+		ok := t.Run(name, func(t *testing.T) {
+			before := schemaSnapshot(t, db)
+
+			require.NoError(t, migrator.UpByOne(ctx), "up failed")
+			require.NoError(t, migrator.Down(ctx), "down failed")
+
+			after := schemaSnapshot(t, db)
+			require.Equal(t, before, after, "schema after down differs from schema before up")
+
+			// Re-apply so the next migration runs on top of it
+			require.NoError(t, migrator.UpByOne(ctx), "re-applying up failed")
+		})
+		if !ok {
+			return
+		}
+	}
+}
+
+// schemaSnapshot describes the tables, columns, indexes and constraints of the current schema,
+// excluding migration bookkeeping tables
+func schemaSnapshot(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`
+		SELECT 'column ' || table_name || '.' || column_name || ' ' || data_type || ' nullable=' || is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name NOT IN ($1, $2)
+		UNION ALL
+		SELECT 'index ' || indexdef
+		FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename NOT IN ($1, $2)
+		UNION ALL
+		SELECT 'constraint ' || conrelid::regclass || ' ' || pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE connamespace = current_schema()::regnamespace AND conrelid::regclass::text NOT IN ($1, $2)
+		ORDER BY 1
+	`, goose.DefaultTablename, migration.ChecksumTable)
+	require.NoError(t, err, "failed to snapshot schema")
+	defer rows.Close()
+
+	var snapshot []string
+	for rows.Next() {
+		var line string
+		require.NoError(t, rows.Scan(&line))
+		snapshot = append(snapshot, line)
+	}
+	require.NoError(t, rows.Err())
+	return snapshot
+}
@@ -0,0 +1,59 @@
+package migrationtest_test
+
+import (
+	"database/sql"
+	"testing"
+
+	migration "sql-migration"
+	"sql-migration/migrationtest"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() migration.Config {
+	return migration.Config{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "postgres",
+		Password: "password",
+		Database: "postgres",
+		SSLMode:  "disable",
+	}
+}
+
+func TestWithMigrations(t *testing.T) {
+	db, err := sql.Open("postgres", testConfig().ConnString())
+	require.NoError(t, err)
+	defer db.Close()
+
+	tableExists := func(table string) bool {
+		var exists bool
+		require.NoError(t, db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists))
+		return exists
+	}
+
+	t.Run("Tables exist during the test", func(t *testing.T) {
+		migrationtest.WithMigrations(t, testConfig())
+
+		assert.True(t, tableExists("users"))
+		assert.True(t, tableExists("orders"))
+	})
+
+	// Cleanup of the subtest rolled everything back
+	assert.False(t, tableExists("users"))
+	assert.False(t, tableExists("orders"))
+
+	t.Run("Teardown can run early", func(t *testing.T) {
+		teardown := migrationtest.WithMigrations(t, testConfig())
+		require.True(t, tableExists("users"))
+
+		teardown()
+		assert.False(t, tableExists("users"))
+	})
+}
+
+func TestEmbeddedMigrationsReversible(t *testing.T) {
+	migrationtest.TestEachMigrationReversible(t, testConfig())
+}
@@ -6,6 +6,9 @@ import (
 	"embed"
 	"fmt"
 	"io/fs"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/pressly/goose/v3"
@@ -22,21 +25,56 @@ type Config struct {
 	Password string
 	Database string
 	SSLMode  string
+	Schema   string // Optional schema for migrated tables and the goose version table (default: public)
+	ReadOnly bool   // Migrations are applied out-of-band (e.g. by DBAs); Up/Down/Reset/DownToDate return ErrExternallyManaged
+
+	TrackChecksums bool // Record a checksum of each applied migration for VerifyChecksums (creates goose_migration_checksums)
 }
 
+// ErrExternallyManaged is returned by write operations of a read-only migrator
+var ErrExternallyManaged = errors.New("migrations are externally managed; migrator is read-only")
+
 // ConnString returns PostgreSQL connection string
 func (c Config) ConnString() string {
 	sslMode := c.SSLMode
 	if sslMode == "" {
 		sslMode = "disable"
 	}
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		c.Host, c.Port, c.User, c.Password, c.Database, sslMode)
+	if c.Schema != "" {
+		// Unqualified migration DDL lands in the schema, matching the schema-qualified version table
+		connStr += " search_path=" + c.Schema
+	}
+	return connStr
 }
 
+// MigratorAPI is the set of migrator operations services depend on
+// Depend on this interface instead of *Migrator to test without a database
+type MigratorAPI interface {
+	Up(ctx context.Context) error
+	Down(ctx context.Context) error
+	Version(ctx context.Context) (int64, error)
+	Status(ctx context.Context) error
+	Close() error
+}
+
+var _ MigratorAPI = (*Migrator)(nil)
+
 // Migrator handles database migrations using embedded SQL files
 type Migrator struct {
-	db *sql.DB
+	db     *sql.DB
+	fsys   fs.FS  // Source of the migrations directory (embedded by default)
+	schema string // Schema of the goose version table (empty means search_path default)
+
+	readOnly  bool // Refuse to apply or roll back migrations
+	checksums bool // Record checksums of applied migrations (TrackChecksums)
+
+	provider *goose.Provider // Isolated migration registry (NewMigratorWithRegistry); nil uses goose's globals
+	versions map[int64]bool  // Versions of the provider's own migrations, excluding goose's global Go migrations
+
+	serverVersion func(ctx context.Context) (int, error) // Overrides the server_version_num query (tests)
+	onApplied     func(AppliedMigration)                 // Called after each migration applied by Run (tests)
 }
 
 // NewMigrator creates a new migrator with database connection
@@ -50,53 +88,340 @@ func NewMigrator(config Config) (*Migrator, error) {
 		return nil, errors.Wrap(err, "failed to ping database")
 	}
 
-	return &Migrator{db: db}, nil
+	return &Migrator{
+		db:        db,
+		fsys:      migrationFS,
+		schema:    config.Schema,
+		readOnly:  config.ReadOnly,
+		checksums: config.TrackChecksums,
+	}, nil
+}
+
+// WithChecksums turns on checksum tracking (see Config.TrackChecksums) and returns m, e.g. for
+// migrators built with NewMigratorWithFS or NewMigratorWithRegistry
+func (m *Migrator) WithChecksums() *Migrator {
+	m.checksums = true
+	return m
 }
 
 // NewMigratorFromDB creates a migrator from existing database connection
 func NewMigratorFromDB(db *sql.DB) *Migrator {
-	return &Migrator{db: db}
+	return &Migrator{db: db, fsys: migrationFS}
 }
 
-// Up runs all pending migrations
-func (m *Migrator) Up(ctx context.Context) error {
-	goose.SetBaseFS(migrationFS)
+// NewMigratorWithFS creates a migrator reading migrations from any fs.FS instead of the embedded files
+// fsys must contain a "migrations" directory at its root; use fs.Sub to re-root other layouts
+// Only fs.FS is required, so adapters over S3/HTTP work as long as they can open files and directories
+func NewMigratorWithFS(db *sql.DB, fsys fs.FS) *Migrator {
+	return &Migrator{db: db, fsys: fsys}
+}
+
+// prepareGoose points goose's global state at this migrator's files, version table and dialect
+// goose keeps these as package globals, so every operation sets them again.
+// Migrators with their own provider leave the globals alone
+func (m *Migrator) prepareGoose() error {
+	if m.provider != nil {
+		return nil
+	}
+	table, err := m.versionTable()
+	if err != nil {
+		return err
+	}
+	goose.SetBaseFS(m.fsys)
+	goose.SetTableName(table)
 
 	if err := goose.SetDialect("postgres"); err != nil {
 		return errors.Wrap(err, "failed to set dialect")
 	}
+	return nil
+}
+
+// checkWritable returns ErrExternallyManaged for read-only migrators
+func (m *Migrator) checkWritable(op string) error {
+	if m.readOnly {
+		return errors.Wrap(ErrExternallyManaged, op)
+	}
+	return nil
+}
+
+// versionTable returns the quoted goose version table name, schema-qualified when a schema is configured
+// Qualifying it keeps goose from missing the table (and re-running migrations) when search_path differs
+func (m *Migrator) versionTable() (string, error) {
+	return m.qualifiedTable(goose.DefaultTablename)
+}
+
+// qualifiedTable quotes a bookkeeping table name, schema-qualified when a schema is configured
+func (m *Migrator) qualifiedTable(name string) (string, error) {
+	table, err := quoteIdent(name)
+	if err != nil || m.schema == "" {
+		return table, err
+	}
+	schema, err := quoteIdent(m.schema)
+	if err != nil {
+		return "", err
+	}
+	return schema + "." + table, nil
+}
+
+// AppliedMigration is a migration applied by UpResult
+type AppliedMigration struct {
+	Version   int64
+	Name      string    // File name, e.g. 001_create_users.sql
+	AppliedAt time.Time // Only set by AppliedSince
+}
+
+// AppliedMigrations lists migrations in the order they were applied
+type AppliedMigrations []AppliedMigration
+
+// Up runs all pending migrations
+func (m *Migrator) Up(ctx context.Context) error {
+	_, err := m.UpResult(ctx)
+	return err
+}
+
+// UpResult runs all pending migrations like Up and returns the ones applied in this run, e.g. for a deploy log
+// Derived from the versions before and after, so it is empty when nothing was pending.
+// When a migration fails, the ones applied before it are returned with the error
+func (m *Migrator) UpResult(ctx context.Context) (AppliedMigrations, error) {
+	if err := m.checkWritable("up"); err != nil {
+		return nil, err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return nil, err
+	}
 
-	if err := goose.UpContext(ctx, m.db, "migrations"); err != nil {
-		return errors.Wrap(err, "failed to run migrations")
+	if err := m.ensureSchema(ctx); err != nil {
+		return nil, err
 	}
 
+	before, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMinServerVersions(ctx, before); err != nil {
+		return nil, err
+	}
+
+	// Migrations applied before a failure are still recorded and returned along with the error
+	upErr := m.gooseUp(ctx)
+	if upErr != nil {
+		upErr = errors.Wrap(upErr, "failed to run migrations")
+	}
+	fail := func(err error) (AppliedMigrations, error) {
+		if upErr != nil {
+			return nil, upErr
+		}
+		return nil, err
+	}
+
+	if err := m.recordChecksums(ctx); err != nil {
+		return fail(err)
+	}
+
+	after, err := m.Version(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	sources, err := m.migrationSources()
+	if err != nil {
+		return fail(err)
+	}
+	var applied AppliedMigrations
+	for _, s := range sources {
+		if s.Version > before && s.Version <= after {
+			applied = append(applied, s)
+		}
+	}
+	return applied, upErr
+}
+
+// ensureSchema creates the configured schema if it doesn't exist yet
+func (m *Migrator) ensureSchema(ctx context.Context) error {
+	if m.schema == "" {
+		return nil
+	}
+	schema, err := quoteIdent(m.schema)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+		return errors.Wrapf(err, "failed to create schema %s", m.schema)
+	}
 	return nil
 }
 
+// quoteIdent double-quotes a Postgres identifier for DDL, which can't take bind parameters
+// Embedded double quotes are doubled; empty, NUL-containing and over-long (>63 bytes) names are rejected
+func quoteIdent(name string) (string, error) {
+	switch {
+	case name == "":
+		return "", errors.New("empty identifier")
+	case strings.ContainsRune(name, 0):
+		return "", errors.Errorf("identifier %q contains a NUL byte", name)
+	case len(name) > 63:
+		return "", errors.Errorf("identifier %q is longer than 63 bytes", name)
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`, nil
+}
+
+// Baseline marks a fresh database as migrated up to version without running any migrations
+// For the "schema dump + baseline" workflow: load a dump of the schema at version, then Baseline so
+// Up only applies newer migrations. Every migration up to version is recorded as applied (goose
+// rejects gaps below the current version); version must match a migration
+func (m *Migrator) Baseline(ctx context.Context, version int64) error {
+	if err := m.checkWritable("baseline"); err != nil {
+		return err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+	if err := m.ensureSchema(ctx); err != nil {
+		return err
+	}
+
+	sources, err := m.migrationSources()
+	if err != nil {
+		return err
+	}
+	var baselined []int64
+	for _, s := range sources {
+		if s.Version <= version {
+			baselined = append(baselined, s.Version)
+		}
+	}
+	if len(baselined) == 0 || baselined[len(baselined)-1] != version {
+		return errors.Errorf("no migration with version %d to baseline at", version)
+	}
+
+	current, err := m.gooseEnsureVersion(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get database version")
+	}
+	if current != 0 {
+		return errors.Errorf("database is already at version %d; baseline only applies to a fresh database", current)
+	}
+
+	table, err := m.versionTable()
+	if err != nil {
+		return err
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin baseline transaction")
+	}
+	defer tx.Rollback()
+	for _, v := range baselined {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" (version_id, is_applied) VALUES ($1, true)", v); err != nil {
+			return errors.Wrapf(err, "failed to record baseline version %d", v)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "failed to commit baseline")
+	}
+
+	return m.recordChecksums(ctx)
+}
+
 // Down rolls back one migration
 func (m *Migrator) Down(ctx context.Context) error {
-	goose.SetBaseFS(migrationFS)
-
-	if err := goose.SetDialect("postgres"); err != nil {
-		return errors.Wrap(err, "failed to set dialect")
+	if err := m.checkWritable("down"); err != nil {
+		return err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return err
 	}
 
-	if err := goose.DownContext(ctx, m.db, "migrations"); err != nil {
+	if err := m.gooseDown(ctx); err != nil {
 		return errors.Wrap(err, "failed to rollback migration")
 	}
 
-	return nil
+	return m.forgetChecksums(ctx)
 }
 
-// Status returns migration status
-func (m *Migrator) Status(ctx context.Context) error {
-	goose.SetBaseFS(migrationFS)
+// UpByOne applies the next pending migration
+func (m *Migrator) UpByOne(ctx context.Context) error {
+	if err := m.checkWritable("up"); err != nil {
+		return err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+	if err := m.ensureSchema(ctx); err != nil {
+		return err
+	}
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return errors.Wrap(err, "failed to set dialect")
+	if err := m.gooseUpByOne(ctx); err != nil {
+		return errors.Wrap(err, "failed to apply migration")
+	}
+
+	return m.recordChecksums(ctx)
+}
+
+// DownTo rolls back every applied migration with a version above version
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	if err := m.checkWritable("down to"); err != nil {
+		return err
 	}
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+
+	if err := m.gooseDownTo(ctx, version); err != nil {
+		return errors.Wrapf(err, "failed to rollback migrations to version %d", version)
+	}
+
+	return m.forgetChecksums(ctx)
+}
+
+// Reset rolls back all applied migrations
+func (m *Migrator) Reset(ctx context.Context) error {
+	if err := m.checkWritable("reset"); err != nil {
+		return err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+
+	if err := m.gooseReset(ctx); err != nil {
+		return errors.Wrap(err, "failed to reset migrations")
+	}
+
+	return m.forgetChecksums(ctx)
+}
 
-	if err := goose.StatusContext(ctx, m.db, "migrations"); err != nil {
+// gooseTimestampFormat is the version layout goose uses for timestamped migrations (UTC)
+const gooseTimestampFormat = "20060102150405"
+
+// DownToDate rolls back every applied migration whose timestamp version is newer than t
+// e.g. revert a bad day's deploys with DownToDate(ctx, startOfDay)
+// Sequentially numbered versions (1, 2, ...) are always older than any timestamp and are left applied
+func (m *Migrator) DownToDate(ctx context.Context, t time.Time) error {
+	if err := m.checkWritable("down to date"); err != nil {
+		return err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+
+	cutoff, err := strconv.ParseInt(t.UTC().Format(gooseTimestampFormat), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert date to migration version")
+	}
+
+	if err := m.gooseDownTo(ctx, cutoff); err != nil {
+		return errors.Wrapf(err, "failed to rollback migrations after %s", t.UTC().Format(time.RFC3339))
+	}
+
+	return m.forgetChecksums(ctx)
+}
+
+// Status writes each migration's state to the migration output (the standard logger unless SetMigrationOutput is used)
+func (m *Migrator) Status(ctx context.Context) error {
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+
+	if err := m.gooseStatus(ctx); err != nil {
 		return errors.Wrap(err, "failed to get migration status")
 	}
 
@@ -105,13 +430,11 @@ func (m *Migrator) Status(ctx context.Context) error {
 
 // Version returns current migration version
 func (m *Migrator) Version(ctx context.Context) (int64, error) {
-	goose.SetBaseFS(migrationFS)
-
-	if err := goose.SetDialect("postgres"); err != nil {
-		return 0, errors.Wrap(err, "failed to set dialect")
+	if err := m.prepareGoose(); err != nil {
+		return 0, err
 	}
 
-	version, err := goose.GetDBVersionContext(ctx, m.db)
+	version, err := m.gooseVersion(ctx)
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to get database version")
 	}
@@ -119,6 +442,84 @@ func (m *Migrator) Version(ctx context.Context) (int64, error) {
 	return version, nil
 }
 
+// PendingCount returns how many migrations have a version above the current database version
+// If the goose version table doesn't exist yet, every migration is pending (the table isn't created)
+// Handy for readiness endpoints and alerting
+func (m *Migrator) PendingCount(ctx context.Context) (int, error) {
+	sources, err := m.migrationSources()
+	if err != nil {
+		return 0, err
+	}
+
+	table, err := m.versionTable()
+	if err != nil {
+		return 0, err
+	}
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		return 0, errors.Wrap(err, "failed to check migration version table")
+	}
+	if !exists {
+		return len(sources), nil
+	}
+
+	version, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range sources {
+		if s.Version > version {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// PendingMigrations lists the migrations with a version above the current database version, oldest first
+func (m *Migrator) PendingMigrations(ctx context.Context) (AppliedMigrations, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := m.migrationSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending AppliedMigrations
+	for _, s := range sources {
+		if s.Version > current {
+			pending = append(pending, s)
+		}
+	}
+	return pending, nil
+}
+
+// WaitForVersion polls the database until its migration version reaches target
+// Useful for app containers that must wait for an init-container migrator to finish
+func (m *Migrator) WaitForVersion(ctx context.Context, target int64, poll time.Duration) error {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for {
+		version, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if version >= target {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "timed out waiting for migration version %d (current %d)", target, version)
+		case <-ticker.C:
+		}
+	}
+}
+
 // Close closes the database connection
 func (m *Migrator) Close() error {
 	return m.db.Close()
@@ -126,17 +527,54 @@ func (m *Migrator) Close() error {
 
 // GetEmbeddedMigrations returns list of embedded migration files for inspection
 func GetEmbeddedMigrations() ([]string, error) {
-	var files []string
+	return ListMigrations(migrationFS)
+}
 
-	err := fs.WalkDir(migrationFS, "migrations", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !d.IsDir() && path != "migrations" {
-			files = append(files, path)
-		}
-		return nil
-	})
+// ListMigrations returns the SQL files directly in the "migrations" directory of fsys
+// Subdirectories are not searched, matching what goose applies
+func ListMigrations(fsys fs.FS) ([]string, error) {
+	if _, err := fs.Stat(fsys, "migrations"); err != nil {
+		return nil, err
+	}
+	return fs.Glob(fsys, "migrations/*.sql")
+}
 
-	return files, err
+// AppliedSince returns migrations recorded in the goose version table after t, oldest first, e.g. for a
+// "what changed in this deploy" notification. AppliedAt is set from the table's tstamp column
+// Rolled back migrations are gone from the table and never returned
+func (m *Migrator) AppliedSince(ctx context.Context, t time.Time) (AppliedMigrations, error) {
+	sources, err := m.migrationSources()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(sources))
+	for _, s := range sources {
+		names[s.Version] = s.Name
+	}
+
+	// tstamp is a timestamp without time zone filled by now(), so compare it in the session time zone
+	table, err := m.versionTable()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, tstamp::timestamptz FROM "+table+
+		" WHERE is_applied AND version_id > 0 AND tstamp::timestamptz > $1 ORDER BY id", t)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query applied migrations")
+	}
+	defer rows.Close()
+
+	var applied AppliedMigrations
+	for rows.Next() {
+		var migration AppliedMigration
+		if err := rows.Scan(&migration.Version, &migration.AppliedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan applied migration")
+		}
+		migration.Name = names[migration.Version]
+		applied = append(applied, migration)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read applied migrations")
+	}
+	return applied, nil
 }
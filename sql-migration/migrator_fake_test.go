@@ -0,0 +1,99 @@
+package migration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMigrator is an in-memory MigratorAPI for unit tests
+type fakeMigrator struct {
+	version    int64
+	maxVersion int64
+	upErr      error
+	upCalls    int
+	closed     bool
+}
+
+func (f *fakeMigrator) Up(ctx context.Context) error {
+	f.upCalls++
+	if f.upErr != nil {
+		return f.upErr
+	}
+	f.version = f.maxVersion
+	return nil
+}
+
+func (f *fakeMigrator) Down(ctx context.Context) error {
+	if f.version > 0 {
+		f.version--
+	}
+	return nil
+}
+
+func (f *fakeMigrator) Version(ctx context.Context) (int64, error) { return f.version, nil }
+
+func (f *fakeMigrator) Status(ctx context.Context) error { return nil }
+
+func (f *fakeMigrator) Close() error {
+	f.closed = true
+	return nil
+}
+
+// schemaBootstrapper is an example consumer that depends on MigratorAPI
+type schemaBootstrapper struct {
+	migrator        MigratorAPI
+	requiredVersion int64
+}
+
+// Bootstrap migrates the schema and checks it reached the required version
+func (b *schemaBootstrapper) Bootstrap(ctx context.Context) error {
+	defer b.migrator.Close()
+
+	if err := b.migrator.Up(ctx); err != nil {
+		return errors.Wrap(err, "failed to migrate schema")
+	}
+
+	version, err := b.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version < b.requiredVersion {
+		return errors.Errorf("schema version %d is below required %d", version, b.requiredVersion)
+	}
+	return nil
+}
+
+func TestConsumerWithFakeMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Bootstrap succeeds", func(t *testing.T) {
+		fake := &fakeMigrator{maxVersion: 2}
+		b := &schemaBootstrapper{migrator: fake, requiredVersion: 2}
+
+		require.NoError(t, b.Bootstrap(ctx))
+		assert.Equal(t, 1, fake.upCalls)
+		assert.True(t, fake.closed)
+	})
+
+	t.Run("Bootstrap fails when schema is behind", func(t *testing.T) {
+		fake := &fakeMigrator{maxVersion: 1}
+		b := &schemaBootstrapper{migrator: fake, requiredVersion: 2}
+
+		err := b.Bootstrap(ctx)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "below required")
+	})
+
+	t.Run("Bootstrap propagates Up errors", func(t *testing.T) {
+		fake := &fakeMigrator{upErr: assert.AnError}
+		b := &schemaBootstrapper{migrator: fake}
+
+		err := b.Bootstrap(ctx)
+		require.ErrorIs(t, err, assert.AnError)
+		assert.True(t, fake.closed)
+	})
+}
@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openOnlyFS hides every optional fs interface (ReadDirFS, ReadFileFS, GlobFS, ...)
+// Mimics a minimal remote adapter that can only open files
+type openOnlyFS struct {
+	fsys fs.FS
+}
+
+func (o openOnlyFS) Open(name string) (fs.File, error) {
+	return o.fsys.Open(name)
+}
+
+func TestNewMigratorWithFS(t *testing.T) {
+	remote := openOnlyFS{fsys: fstest.MapFS{
+		"bucket/app/migrations/001_create_users.sql":          {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"bucket/app/migrations/002_create_orders.sql":         {Data: []byte("-- +goose Up\nSELECT 2;\n")},
+		"bucket/app/migrations/archive/000_legacy.sql":        {Data: []byte("-- +goose Up\nSELECT 0;\n")},
+		"bucket/app/migrations/README.md":                     {Data: []byte("not a migration")},
+		"bucket/other/migrations/001_unrelated_migration.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+	}}
+
+	sub, err := fs.Sub(remote, "bucket/app")
+	require.NoError(t, err)
+
+	t.Run("Lists top-level SQL files only", func(t *testing.T) {
+		files, err := ListMigrations(sub)
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"migrations/001_create_users.sql",
+			"migrations/002_create_orders.sql",
+		}, files)
+	})
+
+	t.Run("Migrator reads versions and checksums from custom FS", func(t *testing.T) {
+		migrator := NewMigratorWithFS(nil, sub)
+
+		files, err := migrator.migrationFiles()
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		assert.Equal(t, int64(1), files[0].Version)
+		assert.Equal(t, int64(2), files[1].Version)
+
+		checksum, err := migrator.fileChecksum(files[0].Path)
+		require.NoError(t, err)
+		assert.Len(t, checksum, 64)
+	})
+
+	t.Run("Missing migrations directory", func(t *testing.T) {
+		_, err := ListMigrations(openOnlyFS{fsys: fstest.MapFS{}})
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+}
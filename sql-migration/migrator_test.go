@@ -1,11 +1,17 @@
 package migration
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -108,3 +114,407 @@ func TestMigrator(t *testing.T) {
 		assert.Contains(t, files, "migrations/002_create_orders.sql")
 	})
 }
+
+// testConfig returns the db-setup connection config (PostgreSQL on localhost:5432)
+func testConfig() Config {
+	return Config{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "postgres",
+		Password: "password",
+		Database: "postgres",
+		SSLMode:  "disable",
+	}
+}
+
+func TestReadOnlyMigrator(t *testing.T) {
+	// Schema is migrated out-of-band first, as a DBA would
+	migrator, err := NewMigrator(testConfig())
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	require.NoError(t, migrator.Up(ctx))
+	expected, err := migrator.Version(ctx)
+	require.NoError(t, err)
+
+	config := testConfig()
+	config.ReadOnly = true
+	readOnly, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer readOnly.Close()
+
+	t.Run("Write methods are blocked", func(t *testing.T) {
+		assert.ErrorIs(t, readOnly.Up(ctx), ErrExternallyManaged)
+		assert.ErrorIs(t, readOnly.Down(ctx), ErrExternallyManaged)
+		assert.ErrorIs(t, readOnly.Reset(ctx), ErrExternallyManaged)
+		assert.ErrorIs(t, readOnly.DownToDate(ctx, time.Now()), ErrExternallyManaged)
+
+		version, err := migrator.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, version, "nothing should have been rolled back")
+	})
+
+	t.Run("Read methods work", func(t *testing.T) {
+		version, err := readOnly.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+
+		pending, err := readOnly.PendingCount(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, pending)
+
+		assert.NoError(t, readOnly.Status(ctx))
+	})
+}
+
+func TestWaitForVersion(t *testing.T) {
+	migrator, err := NewMigrator(testConfig())
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+
+	initialVersion, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Less(t, initialVersion, int64(2), "database should start below target version")
+
+	// Simulate an init-container migrator applying migrations concurrently
+	upErr := make(chan error, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		upErr <- migrator.Up(ctx)
+	}()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = migrator.WaitForVersion(waitCtx, 2, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, <-upErr)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, version, int64(2))
+
+	// Cleanup: roll back both migrations
+	require.NoError(t, migrator.Down(ctx))
+	require.NoError(t, migrator.Down(ctx))
+
+	t.Run("Returns when context is cancelled", func(t *testing.T) {
+		waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+
+		err := migrator.WaitForVersion(waitCtx, 999, 50*time.Millisecond)
+		require.Error(t, err)
+	})
+}
+
+func TestPendingCount(t *testing.T) {
+	migrator, err := NewMigrator(testConfig())
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+
+	t.Run("All pending without version table", func(t *testing.T) {
+		// A schema that doesn't exist yet has no version table
+		config := testConfig()
+		config.Schema = fmt.Sprintf("pending_test_%d", time.Now().UnixNano())
+		fresh, err := NewMigrator(config)
+		require.NoError(t, err)
+		defer fresh.Close()
+
+		pending, err := fresh.PendingCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, pending)
+
+		table, err := fresh.versionTable()
+		require.NoError(t, err)
+		var exists bool
+		require.NoError(t, fresh.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists))
+		assert.False(t, exists, "PendingCount must not create the version table")
+	})
+
+	t.Run("Decreases as migrations are applied", func(t *testing.T) {
+		require.NoError(t, migrator.prepareGoose())
+
+		pending, err := migrator.PendingCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, pending)
+
+		require.NoError(t, goose.UpByOneContext(ctx, migrator.db, "migrations"))
+		pending, err = migrator.PendingCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, pending)
+
+		require.NoError(t, migrator.Up(ctx))
+		pending, err = migrator.PendingCount(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 0, pending)
+
+		// Cleanup: roll back both migrations
+		require.NoError(t, migrator.Down(ctx))
+		require.NoError(t, migrator.Down(ctx))
+	})
+}
+
+func TestMigratorSchema(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("migration_schema_%d", time.Now().UnixNano())
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	require.NoError(t, migrator.Up(ctx))
+
+	var applied int
+	countApplied := func() int {
+		var n int
+		require.NoError(t, migrator.db.QueryRow("SELECT count(*) FROM "+config.Schema+".goose_db_version").Scan(&n))
+		return n
+	}
+	applied = countApplied()
+
+	// Second Up must find the version table and be a no-op (re-applying would fail on existing tables)
+	require.NoError(t, migrator.Up(ctx))
+	assert.Equal(t, applied, countApplied())
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	var exists bool
+	require.NoError(t, migrator.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+".users").Scan(&exists))
+	assert.True(t, exists, "migrated tables should be created in the configured schema")
+
+	// Checksums are opt-in, so plain Up adds no bookkeeping table besides goose's
+	require.NoError(t, migrator.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+"."+ChecksumTable).Scan(&exists))
+	assert.False(t, exists, "checksum table is only created when tracking checksums")
+}
+
+func TestQuoteIdent(t *testing.T) {
+	quoted, err := quoteIdent("tenant_a")
+	require.NoError(t, err)
+	assert.Equal(t, `"tenant_a"`, quoted)
+
+	quoted, err = quoteIdent(`foo"; DROP DATABASE x; --`)
+	require.NoError(t, err)
+	assert.Equal(t, `"foo""; DROP DATABASE x; --"`, quoted)
+
+	for _, name := range []string{"", "a\x00b", strings.Repeat("s", 64)} {
+		_, err := quoteIdent(name)
+		assert.Error(t, err, "%q should be rejected", name)
+	}
+}
+
+func TestVersionTable(t *testing.T) {
+	table, err := (&Migrator{}).versionTable()
+	require.NoError(t, err)
+	assert.Equal(t, `"goose_db_version"`, table)
+
+	table, err = (&Migrator{schema: `tenant"a`}).versionTable()
+	require.NoError(t, err)
+	assert.Equal(t, `"tenant""a"."goose_db_version"`, table)
+
+	_, err = (&Migrator{schema: strings.Repeat("s", 64)}).versionTable()
+	assert.Error(t, err)
+}
+
+func TestBaseline(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("baseline_%d", time.Now().UnixNano())
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	assert.Error(t, migrator.Baseline(ctx, 3), "version without a migration file")
+
+	require.NoError(t, migrator.Baseline(ctx, 2))
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	// Up has nothing left to run, so the (not dumped) tables are never created
+	require.NoError(t, migrator.Up(ctx))
+	var exists bool
+	require.NoError(t, migrator.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+".users").Scan(&exists))
+	assert.False(t, exists, "baselined migrations must not run")
+
+	pending, err := migrator.PendingCount(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+
+	assert.Error(t, migrator.Baseline(ctx, 2), "baseline requires a fresh database")
+}
+
+func TestUpResult(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("up_result_%d", time.Now().UnixNano())
+	config.TrackChecksums = true
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	applied, err := migrator.UpResult(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, AppliedMigrations{
+		{Version: 1, Name: "001_create_users.sql"},
+		{Version: 2, Name: "002_create_orders.sql"},
+	}, applied)
+
+	applied, err = migrator.UpResult(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "nothing pending on the second run")
+
+	// A failing migration still reports (and checksums) the ones applied before it
+	migrator.fsys = fstest.MapFS{
+		"migrations/001_create_users.sql":  {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"migrations/002_create_orders.sql": {Data: []byte("-- +goose Up\nSELECT 2;\n")},
+		"migrations/003_create_audit.sql":  {Data: []byte("-- +goose Up\nCREATE TABLE audit (id INT);\n")},
+		"migrations/004_broken.sql":        {Data: []byte("-- +goose Up\nSELECT * FROM missing_table;\n")},
+	}
+	applied, err = migrator.UpResult(ctx)
+	require.Error(t, err)
+	assert.Equal(t, AppliedMigrations{{Version: 3, Name: "003_create_audit.sql"}}, applied)
+
+	var checksummed bool
+	require.NoError(t, migrator.db.QueryRow("SELECT EXISTS (SELECT 1 FROM "+config.Schema+"."+ChecksumTable+" WHERE version_id = 3)").Scan(&checksummed))
+	assert.True(t, checksummed, "checksum of the applied migration is recorded")
+}
+
+func TestDownToDate(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("down_to_date_%d", time.Now().UnixNano())
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	migration := func(table string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(fmt.Sprintf(
+			"-- +goose Up\nCREATE TABLE %s (id INT);\n\n-- +goose Down\nDROP TABLE %s;\n", table, table))}
+	}
+	migrator.fsys = fstest.MapFS{
+		"migrations/20240101000000_create_old.sql":      migration("old_table"),
+		"migrations/20240102090000_create_morning.sql":  migration("morning_table"),
+		"migrations/20240102150000_create_evening.sql":  migration("evening_table"),
+		"migrations/20240102180000_create_evening2.sql": migration("evening2_table"),
+	}
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	require.NoError(t, migrator.Up(ctx))
+
+	cutoff := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, migrator.DownToDate(ctx, cutoff))
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20240102090000), version)
+
+	tableExists := func(table string) bool {
+		var exists bool
+		require.NoError(t, migrator.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+"."+table).Scan(&exists))
+		return exists
+	}
+	assert.True(t, tableExists("old_table"))
+	assert.True(t, tableExists("morning_table"))
+	assert.False(t, tableExists("evening_table"), "migration after cutoff should be rolled back")
+	assert.False(t, tableExists("evening2_table"), "migration after cutoff should be rolled back")
+}
+
+func TestAppliedSince(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("applied_since_%d", time.Now().UnixNano())
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	migration := func(table string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(fmt.Sprintf(
+			"-- +goose Up\nCREATE TABLE %s (id INT);\n\n-- +goose Down\nDROP TABLE %s;\n", table, table))}
+	}
+	fsys := fstest.MapFS{
+		"migrations/001_create_first.sql":  migration("first_table"),
+		"migrations/002_create_second.sql": migration("second_table"),
+	}
+	migrator.fsys = fsys
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	require.NoError(t, migrator.Up(ctx))
+
+	time.Sleep(10 * time.Millisecond)
+	deployStart := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	fsys["migrations/003_create_third.sql"] = migration("third_table")
+	fsys["migrations/004_create_fourth.sql"] = migration("fourth_table")
+	require.NoError(t, migrator.Up(ctx))
+
+	applied, err := migrator.AppliedSince(ctx, deployStart)
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	assert.Equal(t, int64(3), applied[0].Version)
+	assert.Equal(t, "003_create_third.sql", applied[0].Name)
+	assert.Equal(t, int64(4), applied[1].Version)
+	assert.Equal(t, "004_create_fourth.sql", applied[1].Name)
+	assert.True(t, applied[0].AppliedAt.After(deployStart))
+
+	all, err := migrator.AppliedSince(ctx, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+}
+
+func TestSetMigrationOutput(t *testing.T) {
+	var out bytes.Buffer
+	SetMigrationOutput(&out)
+	t.Cleanup(func() { SetMigrationOutput(nil) })
+
+	migrator, err := NewMigrator(testConfig())
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	require.NoError(t, migrator.Up(context.Background()))
+	t.Cleanup(func() { assert.NoError(t, migrator.Reset(context.Background())) })
+
+	out.Reset()
+	require.NoError(t, migrator.Status(context.Background()))
+
+	assert.NotEmpty(t, out.String())
+	assert.Contains(t, out.String(), "Applied At")
+	assert.Contains(t, out.String(), "001_create_users.sql")
+	assert.Contains(t, out.String(), "002_create_orders.sql")
+}
@@ -0,0 +1,22 @@
+package migration
+
+import (
+	"io"
+	"log"
+
+	"github.com/pressly/goose/v3"
+)
+
+// migrationOutput receives goose's human-readable output, such as Status tables and applied migrations
+var migrationOutput goose.Logger = log.Default()
+
+// SetMigrationOutput writes goose's human-readable output (Status tables, applied and rolled back migrations)
+// to w instead of the standard logger, e.g. to keep it in a deploy log artifact; nil restores the standard logger
+// goose's logger is package-global, so this applies to every Migrator; call it before running migrations
+func SetMigrationOutput(w io.Writer) {
+	migrationOutput = log.Default()
+	if w != nil {
+		migrationOutput = log.New(w, "", log.LstdFlags)
+	}
+	goose.SetLogger(migrationOutput)
+}
@@ -0,0 +1,236 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+// NewMigratorWithRegistry creates a migrator backed by its own goose.Provider instead of goose's package globals
+// SQL files come from fsys's "migrations" directory (as with NewMigratorWithFS) and Go migrations from
+// goMigrations (built with goose.NewGoMigration), so migrators with different migration sets don't
+// interfere, e.g. across tests. Go migrations registered globally with goose.AddMigration are ignored
+func NewMigratorWithRegistry(db *sql.DB, fsys fs.FS, goMigrations ...*goose.Migration) (*Migrator, error) {
+	dir, err := fs.Sub(fsys, "migrations")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open migrations directory")
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, dir, goose.WithGoMigrations(goMigrations...))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create migration provider")
+	}
+
+	// goose v3.16 has no WithDisableGlobalRegistry: the provider also lists the global Go migrations,
+	// so remember which versions are the migrator's own and only ever run those
+	versions := make(map[int64]bool)
+	for _, s := range provider.ListSources() {
+		if s.Type == goose.TypeSQL {
+			versions[s.Version] = true
+		}
+	}
+	for _, gm := range goMigrations {
+		versions[gm.Version] = true
+	}
+
+	return &Migrator{db: db, fsys: fsys, provider: provider, versions: versions}, nil
+}
+
+// providerSources lists the provider's own migrations, ordered by version
+func (m *Migrator) providerSources() []*goose.Source {
+	var sources []*goose.Source
+	for _, s := range m.provider.ListSources() {
+		if m.versions[s.Version] {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// providerStatus returns the state of the provider's own migrations, ordered by version
+func (m *Migrator) providerStatus(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var own []*goose.MigrationStatus
+	for _, s := range statuses {
+		if m.versions[s.Source.Version] {
+			own = append(own, s)
+		}
+	}
+	return own, nil
+}
+
+// providerUp applies the provider's pending migrations in version order, only the first one when byOne is set
+// Like goose, it refuses to apply a pending migration older than one already applied
+func (m *Migrator) providerUp(ctx context.Context, byOne bool) error {
+	statuses, err := m.providerStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var latest int64
+	for _, s := range statuses {
+		if s.State == goose.StateApplied {
+			latest = s.Source.Version
+		}
+	}
+
+	applied := 0
+	for _, s := range statuses {
+		if s.State != goose.StatePending {
+			continue
+		}
+		if s.Source.Version < latest {
+			return errors.Errorf("missing migration %d before current version %d", s.Source.Version, latest)
+		}
+		if _, err := m.provider.ApplyVersion(ctx, s.Source.Version, true); err != nil {
+			return err
+		}
+		applied++
+		if byOne {
+			return nil
+		}
+	}
+	if byOne && applied == 0 {
+		return goose.ErrNoNextVersion
+	}
+	return nil
+}
+
+// providerDownTo rolls back the provider's applied migrations above version, newest first,
+// only the newest one when byOne is set
+func (m *Migrator) providerDownTo(ctx context.Context, version int64, byOne bool) error {
+	statuses, err := m.providerStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	rolledBack := 0
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if s.State != goose.StateApplied || s.Source.Version <= version {
+			continue
+		}
+		if _, err := m.provider.ApplyVersion(ctx, s.Source.Version, false); err != nil {
+			return err
+		}
+		rolledBack++
+		if byOne {
+			return nil
+		}
+	}
+	if byOne && rolledBack == 0 {
+		return goose.ErrNoNextVersion
+	}
+	return nil
+}
+
+// migrationSources lists every migration the migrator knows, ordered by version
+// Includes a provider's Go migrations, whose Name is empty when they have no file
+func (m *Migrator) migrationSources() ([]AppliedMigration, error) {
+	var sources []AppliedMigration
+	if m.provider != nil {
+		for _, s := range m.providerSources() {
+			source := AppliedMigration{Version: s.Version}
+			if s.Path != "" {
+				source.Name = path.Base(s.Path)
+			}
+			sources = append(sources, source)
+		}
+		return sources, nil
+	}
+
+	files, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		sources = append(sources, AppliedMigration{Version: f.Version, Name: path.Base(f.Path)})
+	}
+	return sources, nil
+}
+
+// The goose* helpers run an operation through the migrator's provider, or through goose's globals
+// (set by prepareGoose) for migrators without one
+
+func (m *Migrator) gooseUp(ctx context.Context) error {
+	if m.provider != nil {
+		return m.providerUp(ctx, false)
+	}
+	return goose.UpContext(ctx, m.db, "migrations")
+}
+
+func (m *Migrator) gooseUpByOne(ctx context.Context) error {
+	if m.provider != nil {
+		return m.providerUp(ctx, true)
+	}
+	return goose.UpByOneContext(ctx, m.db, "migrations")
+}
+
+func (m *Migrator) gooseDown(ctx context.Context) error {
+	if m.provider != nil {
+		return m.providerDownTo(ctx, 0, true)
+	}
+	return goose.DownContext(ctx, m.db, "migrations")
+}
+
+func (m *Migrator) gooseDownTo(ctx context.Context, version int64) error {
+	if m.provider != nil {
+		return m.providerDownTo(ctx, version, false)
+	}
+	return goose.DownToContext(ctx, m.db, "migrations", version)
+}
+
+func (m *Migrator) gooseReset(ctx context.Context) error {
+	if m.provider != nil {
+		return m.gooseDownTo(ctx, 0)
+	}
+	return goose.ResetContext(ctx, m.db, "migrations")
+}
+
+func (m *Migrator) gooseVersion(ctx context.Context) (int64, error) {
+	if m.provider != nil {
+		return m.provider.GetDBVersion(ctx)
+	}
+	return goose.GetDBVersionContext(ctx, m.db)
+}
+
+// gooseEnsureVersion returns the current version, creating the version table if it doesn't exist yet
+func (m *Migrator) gooseEnsureVersion(ctx context.Context) (int64, error) {
+	if m.provider != nil {
+		// The provider creates its version table on first use
+		return m.provider.GetDBVersion(ctx)
+	}
+	return goose.EnsureDBVersionContext(ctx, m.db)
+}
+
+// gooseStatus logs each migration's state to the migration output; providers return it instead of logging it
+func (m *Migrator) gooseStatus(ctx context.Context) error {
+	if m.provider == nil {
+		return goose.StatusContext(ctx, m.db, "migrations")
+	}
+
+	statuses, err := m.providerStatus(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		appliedAt := "Pending"
+		if s.State == goose.StateApplied {
+			appliedAt = s.AppliedAt.Format("Mon Jan _2 15:04:05 2006")
+		}
+		name := s.Source.Path
+		if name == "" {
+			name = "go migration " + strconv.FormatInt(s.Source.Version, 10)
+		}
+		migrationOutput.Printf("    %-24s -- %v", appliedAt, name)
+	}
+	return nil
+}
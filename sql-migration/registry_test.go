@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMigratorWithRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	// openInSchema connects with search_path set to a fresh schema, so each migrator gets its own version table
+	openInSchema := func(t *testing.T, prefix string) (*sql.DB, string) {
+		config := testConfig()
+		config.Schema = fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+
+		db, err := sql.Open("postgres", config.ConnString())
+		require.NoError(t, err)
+		_, err = db.Exec(fmt.Sprintf(`CREATE SCHEMA "%s"`, config.Schema))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_, err := db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+			assert.NoError(t, err)
+			db.Close()
+		})
+		return db, config.Schema
+	}
+
+	migration := func(table string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(fmt.Sprintf(
+			"-- +goose Up\nCREATE TABLE %s (id INT);\n\n-- +goose Down\nDROP TABLE %s;\n", table, table))}
+	}
+
+	// A global Go migration must not leak into either migrator
+	require.NoError(t, goose.SetGlobalMigrations(goose.NewGoMigration(50,
+		&goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE global_go (id INT)")
+			return err
+		}},
+		nil,
+	)))
+	t.Cleanup(goose.ResetGlobalMigrations)
+
+	dbA, schemaA := openInSchema(t, "registry_a")
+	migratorA, err := NewMigratorWithRegistry(dbA, fstest.MapFS{
+		"migrations/001_create_alpha.sql": migration("alpha"),
+	}, goose.NewGoMigration(2,
+		&goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "CREATE TABLE alpha_go (id INT)")
+			return err
+		}},
+		&goose.GoFunc{RunTx: func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "DROP TABLE alpha_go")
+			return err
+		}},
+	))
+	require.NoError(t, err)
+
+	dbB, schemaB := openInSchema(t, "registry_b")
+	migratorB, err := NewMigratorWithRegistry(dbB, fstest.MapFS{
+		"migrations/001_create_beta.sql": migration("beta"),
+	})
+	require.NoError(t, err)
+
+	// The Go migration counts as pending too
+	pendingA, err := migratorA.PendingCount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pendingA)
+
+	appliedA, err := migratorA.UpResult(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, AppliedMigrations{
+		{Version: 1, Name: "001_create_alpha.sql"},
+		{Version: 2},
+	}, appliedA)
+
+	appliedB, err := migratorB.UpResult(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, AppliedMigrations{{Version: 1, Name: "001_create_beta.sql"}}, appliedB)
+
+	tableExists := func(schema, table string) bool {
+		var exists bool
+		require.NoError(t, dbA.QueryRow("SELECT to_regclass($1) IS NOT NULL", schema+"."+table).Scan(&exists))
+		return exists
+	}
+	assert.True(t, tableExists(schemaA, "alpha"))
+	assert.True(t, tableExists(schemaA, "alpha_go"))
+	assert.False(t, tableExists(schemaA, "beta"), "A must not apply B's migrations")
+	assert.True(t, tableExists(schemaB, "beta"))
+	assert.False(t, tableExists(schemaB, "alpha"), "B must not apply A's migrations")
+	assert.False(t, tableExists(schemaB, "alpha_go"), "B must not apply A's Go migrations")
+	assert.False(t, tableExists(schemaA, "global_go"), "A must not apply global Go migrations")
+	assert.False(t, tableExists(schemaB, "global_go"), "B must not apply global Go migrations")
+
+	versionA, err := migratorA.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), versionA)
+
+	require.NoError(t, migratorA.Reset(ctx))
+	assert.False(t, tableExists(schemaA, "alpha_go"))
+	assert.True(t, tableExists(schemaB, "beta"), "resetting A leaves B alone")
+
+	// Provider-backed migrators leave goose's package globals alone
+	goose.SetTableName("sentinel_version_table")
+	t.Cleanup(func() { goose.SetTableName(goose.DefaultTablename) })
+	require.NoError(t, migratorA.Baseline(ctx, 1))
+	require.NoError(t, migratorA.Status(ctx))
+	assert.Equal(t, "sentinel_version_table", goose.TableName())
+}
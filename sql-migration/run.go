@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInterrupted is returned by Run when it stopped before applying every pending migration
+var ErrInterrupted = errors.New("migration run interrupted")
+
+// Run applies pending migrations one at a time until done or until SIGTERM/SIGINT (or ctx) cancels it
+// For migration jobs in pods: the migration in progress always runs to completion (in its own transaction),
+// then Run stops before the next one and returns ErrInterrupted, so no migration is left half-applied.
+// Returns the migrations applied in this run either way
+func (m *Migrator) Run(ctx context.Context) (AppliedMigrations, error) {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	return m.runOneByOne(ctx)
+}
+
+// runOneByOne applies pending migrations one by one, checking ctx between migrations
+func (m *Migrator) runOneByOne(ctx context.Context) (AppliedMigrations, error) {
+	if err := m.checkWritable("up"); err != nil {
+		return nil, err
+	}
+	if err := m.prepareGoose(); err != nil {
+		return nil, err
+	}
+	if err := m.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+
+	current, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkMinServerVersions(ctx, current); err != nil {
+		return nil, err
+	}
+
+	sources, err := m.migrationSources()
+	if err != nil {
+		return nil, err
+	}
+	var pending []AppliedMigration
+	for _, s := range sources {
+		if s.Version > current {
+			pending = append(pending, s)
+		}
+	}
+
+	// A cancelled context must not abort the migration in progress, only prevent the next one
+	uncancelled := context.WithoutCancel(ctx)
+
+	var applied AppliedMigrations
+	for _, migration := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := m.gooseUpByOne(uncancelled); err != nil {
+			return applied, errors.Wrapf(err, "failed to apply migration %d", migration.Version)
+		}
+		applied = append(applied, migration)
+		if m.onApplied != nil {
+			m.onApplied(migration)
+		}
+	}
+
+	if err := m.recordChecksums(uncancelled); err != nil {
+		return applied, err
+	}
+	if len(applied) < len(pending) {
+		return applied, errors.Wrapf(ErrInterrupted, "applied %d of %d pending migrations", len(applied), len(pending))
+	}
+	return applied, nil
+}
@@ -0,0 +1,89 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("run_%d", time.Now().UnixNano())
+	config.TrackChecksums = true
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	migration := func(table string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(fmt.Sprintf(
+			"-- +goose Up\nCREATE TABLE %s (id INT);\n\n-- +goose Down\nDROP TABLE %s;\n", table, table))}
+	}
+	migrator.fsys = fstest.MapFS{
+		"migrations/001_create_first.sql":  migration("first_table"),
+		"migrations/002_create_second.sql": migration("second_table"),
+		"migrations/003_create_third.sql":  migration("third_table"),
+	}
+
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	tableExists := func(table string) bool {
+		var exists bool
+		require.NoError(t, migrator.db.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+"."+table).Scan(&exists))
+		return exists
+	}
+	assertVersion := func(expected int64) {
+		version, err := migrator.Version(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, expected, version)
+	}
+
+	t.Run("Cancellation stops before the next migration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		migrator.onApplied = func(AppliedMigration) { cancel() }
+
+		applied, err := migrator.Run(ctx)
+		require.ErrorIs(t, err, ErrInterrupted)
+		assert.Equal(t, AppliedMigrations{{Version: 1, Name: "001_create_first.sql"}}, applied)
+
+		assertVersion(1)
+		assert.True(t, tableExists("first_table"))
+		assert.False(t, tableExists("second_table"))
+	})
+
+	t.Run("SIGTERM stops before the next migration", func(t *testing.T) {
+		migrator.onApplied = func(AppliedMigration) {
+			require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+			time.Sleep(50 * time.Millisecond) // Let the signal be delivered
+		}
+
+		applied, err := migrator.Run(context.Background())
+		require.ErrorIs(t, err, ErrInterrupted)
+		assert.Equal(t, AppliedMigrations{{Version: 2, Name: "002_create_second.sql"}}, applied)
+		assertVersion(2)
+	})
+
+	t.Run("Resumes where it stopped", func(t *testing.T) {
+		migrator.onApplied = nil
+
+		applied, err := migrator.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, AppliedMigrations{{Version: 3, Name: "003_create_third.sql"}}, applied)
+		assertVersion(3)
+
+		mismatches, err := migrator.VerifyChecksums(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, mismatches)
+	})
+}
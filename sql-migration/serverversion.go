@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// minVersionAnnotation gates a migration on the Postgres server version, e.g. "-- +goose MIN_VERSION 120000" for 12+
+// The value is compared with server_version_num; goose itself ignores the line as a comment
+const minVersionAnnotation = "-- +goose MIN_VERSION"
+
+// ErrServerTooOld is returned by Up when a pending migration requires a newer Postgres server
+var ErrServerTooOld = errors.New("database server is too old for migration")
+
+// MinServerVersion returns the MIN_VERSION annotation of a migration file, or 0 when it has none
+func MinServerVersion(body []byte) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, minVersionAnnotation) {
+			continue
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(line, minVersionAnnotation))
+		version, err := strconv.Atoi(value)
+		if err != nil || version <= 0 {
+			return 0, errors.Errorf("invalid MIN_VERSION %q, expected a server_version_num like 120000", value)
+		}
+		return version, nil
+	}
+	return 0, scanner.Err()
+}
+
+// queryServerVersion returns the server's server_version_num (e.g. 160002 for 16.2)
+func (m *Migrator) queryServerVersion(ctx context.Context) (int, error) {
+	if m.serverVersion != nil {
+		return m.serverVersion(ctx)
+	}
+
+	var version int
+	if err := m.db.QueryRowContext(ctx, "SHOW server_version_num").Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "failed to read server version")
+	}
+	return version, nil
+}
+
+// checkMinServerVersions fails if a migration newer than current requires a newer server
+// The server is only queried when a pending migration is annotated
+func (m *Migrator) checkMinServerVersions(ctx context.Context, current int64) error {
+	files, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	server := 0
+	for _, f := range files {
+		if f.Version <= current {
+			continue
+		}
+		body, err := fs.ReadFile(m.fsys, f.Path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read migration file %s", f.Path)
+		}
+		required, err := MinServerVersion(body)
+		if err != nil {
+			return errors.Wrapf(err, "migration %s", f.Path)
+		}
+		if required == 0 {
+			continue
+		}
+
+		if server == 0 {
+			if server, err = m.queryServerVersion(ctx); err != nil {
+				return err
+			}
+		}
+		if server < required {
+			return errors.Wrapf(ErrServerTooOld, "migration %s requires server version %d, server is %d", f.Path, required, server)
+		}
+	}
+	return nil
+}
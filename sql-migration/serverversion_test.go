@@ -0,0 +1,57 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinServerVersion(t *testing.T) {
+	t.Run("Parses annotation", func(t *testing.T) {
+		version, err := MinServerVersion([]byte("-- +goose Up\n-- +goose MIN_VERSION 120000\nCREATE TABLE t (id INT);\n"))
+		require.NoError(t, err)
+		assert.Equal(t, 120000, version)
+	})
+
+	t.Run("Zero without annotation", func(t *testing.T) {
+		version, err := MinServerVersion([]byte("-- +goose Up\nCREATE TABLE t (id INT);\n"))
+		require.NoError(t, err)
+		assert.Zero(t, version)
+	})
+
+	t.Run("Rejects malformed value", func(t *testing.T) {
+		_, err := MinServerVersion([]byte("-- +goose MIN_VERSION 12.0\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestCheckMinServerVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/00001_create_items.sql": {Data: []byte("-- +goose Up\nCREATE TABLE items (id INT);\n")},
+		"migrations/00002_add_generated.sql": {Data: []byte("-- +goose Up\n-- +goose MIN_VERSION 120000\n" +
+			"ALTER TABLE items ADD COLUMN double INT GENERATED ALWAYS AS (id * 2) STORED;\n")},
+	}
+	// Simulated server: no database needed
+	migratorOn := func(server int) *Migrator {
+		return &Migrator{fsys: fsys, serverVersion: func(ctx context.Context) (int, error) { return server, nil }}
+	}
+	ctx := context.Background()
+
+	t.Run("Older server fails naming the migration", func(t *testing.T) {
+		err := migratorOn(110000).checkMinServerVersions(ctx, 0)
+		require.ErrorIs(t, err, ErrServerTooOld)
+		assert.Contains(t, err.Error(), "00002_add_generated.sql")
+		assert.Contains(t, err.Error(), "120000")
+	})
+
+	t.Run("Newer server passes", func(t *testing.T) {
+		assert.NoError(t, migratorOn(160002).checkMinServerVersions(ctx, 0))
+	})
+
+	t.Run("Applied migrations are not checked", func(t *testing.T) {
+		assert.NoError(t, migratorOn(110000).checkMinServerVersions(ctx, 2))
+	})
+}
@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io/fs"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pressly/goose/v3"
+)
+
+// noTransactionAnnotation marks migrations goose runs outside a transaction (e.g. CREATE INDEX CONCURRENTLY)
+const noTransactionAnnotation = "-- +goose NO TRANSACTION"
+
+// UpTx applies pending migrations inside tx, so they commit or roll back together with the caller's other
+// setup work (e.g. schema + seed data). Nothing is committed here; the caller owns tx.
+// Postgres runs DDL transactionally, which is what makes this atomic (engines like MySQL commit implicitly
+// on DDL). Only SQL migrations are supported: migrations marked NO TRANSACTION, pending Go migrations and
+// registry-backed migrators (NewMigratorWithRegistry) are rejected before anything runs
+func (m *Migrator) UpTx(ctx context.Context, tx *sql.Tx) error {
+	if err := m.checkWritable("up"); err != nil {
+		return err
+	}
+	if m.provider != nil {
+		return errors.New("UpTx doesn't support registry-backed migrators; use Up")
+	}
+
+	if m.schema != "" {
+		schema, err := quoteIdent(m.schema)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+schema); err != nil {
+			return errors.Wrapf(err, "failed to create schema %s", m.schema)
+		}
+	}
+
+	current, err := m.ensureVersionTableTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := m.checkMinServerVersions(ctx, current); err != nil {
+		return err
+	}
+	if err := m.rejectGoMigrations(current); err != nil {
+		return err
+	}
+
+	table, err := m.versionTable()
+	if err != nil {
+		return err
+	}
+	files, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	// Read every pending file first, so a NO TRANSACTION migration fails the call before anything runs
+	bodies := make(map[int64][]byte, len(files))
+	for _, f := range files {
+		if f.Version <= current {
+			continue
+		}
+		body, err := fs.ReadFile(m.fsys, f.Path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read migration file %s", f.Path)
+		}
+		if bytes.Contains(body, []byte(noTransactionAnnotation)) {
+			return errors.Errorf("migration %s is marked NO TRANSACTION and can't run in a caller's transaction", f.Path)
+		}
+		bodies[f.Version] = body
+	}
+
+	for _, f := range files {
+		body, ok := bodies[f.Version]
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, upSection(body)); err != nil {
+			return errors.Wrapf(err, "failed to apply migration %s", f.Path)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" (version_id, is_applied) VALUES ($1, true)", f.Version); err != nil {
+			return errors.Wrapf(err, "failed to record version %d", f.Version)
+		}
+		current = f.Version
+	}
+
+	return m.insertChecksums(ctx, tx, current)
+}
+
+// rejectGoMigrations fails if a Go migration registered with goose is pending above current
+// UpTx only runs SQL files, so it would otherwise skip the Go migration and record later versions past it
+func (m *Migrator) rejectGoMigrations(current int64) error {
+	if err := m.prepareGoose(); err != nil {
+		return err
+	}
+	migrations, err := goose.CollectMigrations("migrations", current, goose.MaxVersion)
+	if errors.Is(err, goose.ErrNoMigrationFiles) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to collect migrations")
+	}
+	for _, gm := range migrations {
+		if gm.Type == goose.TypeGo {
+			return errors.Errorf("migration %d is a Go migration, which UpTx can't run; use Up", gm.Version)
+		}
+	}
+	return nil
+}
+
+// ensureVersionTableTx creates the goose version table in tx if missing and returns the current version
+// Mirrors goose's layout, so Up/Version keep working on the same table after the transaction commits
+func (m *Migrator) ensureVersionTableTx(ctx context.Context, tx *sql.Tx) (int64, error) {
+	table, err := m.versionTable()
+	if err != nil {
+		return 0, err
+	}
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		return 0, errors.Wrap(err, "failed to check migration version table")
+	}
+	if !exists {
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE `+table+` (
+			id serial NOT NULL,
+			version_id bigint NOT NULL,
+			is_applied boolean NOT NULL,
+			tstamp timestamp NULL default now(),
+			PRIMARY KEY(id)
+		)`); err != nil {
+			return 0, errors.Wrap(err, "failed to create migration version table")
+		}
+		// goose starts every version table with version 0
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+table+" (version_id, is_applied) VALUES (0, true)"); err != nil {
+			return 0, errors.Wrap(err, "failed to initialize migration version table")
+		}
+	}
+
+	var current int64
+	query := "SELECT COALESCE(MAX(version_id), 0) FROM " + table + " WHERE is_applied"
+	if err := tx.QueryRowContext(ctx, query).Scan(&current); err != nil {
+		return 0, errors.Wrap(err, "failed to get database version")
+	}
+	return current, nil
+}
+
+// upSection returns the SQL between the "+goose Up" and "+goose Down" annotations
+// Postgres accepts the whole section as one multi-statement Exec, so StatementBegin/End need no handling
+func upSection(body []byte) string {
+	var up strings.Builder
+	in := false
+	for _, line := range strings.Split(string(body), "\n") {
+		switch trimmed := strings.TrimSpace(line); {
+		case strings.HasPrefix(trimmed, "-- +goose Up"):
+			in = true
+		case strings.HasPrefix(trimmed, "-- +goose Down"):
+			in = false
+		case in:
+			up.WriteString(line)
+			up.WriteByte('\n')
+		}
+	}
+	return up.String()
+}
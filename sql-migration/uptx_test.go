@@ -0,0 +1,135 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpSection(t *testing.T) {
+	body := []byte(`-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE a (id INT);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE a;
+`)
+	up := upSection(body)
+	assert.Contains(t, up, "CREATE TABLE a (id INT);")
+	assert.NotContains(t, up, "DROP TABLE")
+}
+
+func TestUpTxRejectsRegistryMigrators(t *testing.T) {
+	// sql.Open doesn't connect, and UpTx fails before touching the database or tx
+	db, err := sql.Open("postgres", testConfig().ConnString())
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := NewMigratorWithRegistry(db, fstest.MapFS{
+		"migrations/001_create_alpha.sql": {Data: []byte("-- +goose Up\nCREATE TABLE alpha (id INT);\n")},
+	})
+	require.NoError(t, err)
+	assert.ErrorContains(t, migrator.UpTx(context.Background(), nil), "registry-backed")
+}
+
+func TestUpTx(t *testing.T) {
+	config := testConfig()
+	config.Schema = fmt.Sprintf("up_tx_%d", time.Now().UnixNano())
+	config.TrackChecksums = true
+
+	migrator, err := NewMigrator(config)
+	require.NoError(t, err)
+	defer migrator.Close()
+
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := migrator.db.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS "%s" CASCADE`, config.Schema))
+		assert.NoError(t, err)
+	})
+
+	tableExists := func(q interface {
+		QueryRow(query string, args ...any) *sql.Row
+	}, table string) bool {
+		var exists bool
+		require.NoError(t, q.QueryRow("SELECT to_regclass($1) IS NOT NULL", config.Schema+"."+table).Scan(&exists))
+		return exists
+	}
+
+	t.Run("Rolled back with the caller's transaction", func(t *testing.T) {
+		tx, err := migrator.db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, migrator.UpTx(ctx, tx))
+		assert.True(t, tableExists(tx, "users"), "visible inside the transaction")
+
+		require.NoError(t, tx.Rollback())
+		assert.False(t, tableExists(migrator.db, "users"))
+		assert.False(t, tableExists(migrator.db, "goose_db_version"))
+	})
+
+	t.Run("Committed with the caller's transaction", func(t *testing.T) {
+		tx, err := migrator.db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, migrator.UpTx(ctx, tx))
+		_, err = tx.Exec("INSERT INTO " + config.Schema + ".users (name, email) VALUES ('Seed', 'seed@example.com')")
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		version, err := migrator.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), version)
+
+		// goose sees the same version table, so Up has nothing left to do
+		require.NoError(t, migrator.Up(ctx))
+		mismatches, err := migrator.VerifyChecksums(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, mismatches)
+	})
+
+	// withFiles returns a migrator over the same connection and schema reading migrations from files
+	withFiles := func(files fstest.MapFS) *Migrator {
+		other := NewMigratorWithFS(migrator.db, files)
+		other.schema = config.Schema
+		return other
+	}
+
+	t.Run("Rejects NO TRANSACTION migrations", func(t *testing.T) {
+		other := withFiles(fstest.MapFS{
+			"migrations/003_create_audit.sql": {Data: []byte("-- +goose Up\nCREATE TABLE audit (id INT);\n")},
+			"migrations/004_index_audit.sql": {Data: []byte(
+				"-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY audit_id ON audit (id);\n")},
+		})
+
+		tx, err := migrator.db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		assert.ErrorContains(t, other.UpTx(ctx, tx), "NO TRANSACTION")
+		assert.False(t, tableExists(tx, "audit"), "nothing runs once a migration is rejected")
+	})
+
+	t.Run("Rejects pending Go migrations", func(t *testing.T) {
+		goose.AddNamedMigrationContext("003_backfill.go", func(ctx context.Context, tx *sql.Tx) error { return nil }, nil)
+		t.Cleanup(goose.ResetGlobalMigrations)
+
+		other := withFiles(fstest.MapFS{
+			"migrations/004_create_audit.sql": {Data: []byte("-- +goose Up\nCREATE TABLE audit (id INT);\n")},
+		})
+
+		tx, err := migrator.db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		assert.ErrorContains(t, other.UpTx(ctx, tx), "Go migration")
+		assert.False(t, tableExists(tx, "audit"), "no version may be recorded past the Go migration")
+	})
+}